@@ -0,0 +1,150 @@
+package awsiam
+
+import (
+	"context"
+	"time"
+
+	awsv2 "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	credentialsv2 "github.com/aws/aws-sdk-go-v2/credentials"
+	stscredsv2 "github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	stsv2 "github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pkg/errors"
+)
+
+// NewConfig returns an aws-sdk-go-v2 config using the credentials. The shared config files and the default credential
+// chain are loaded as by config.LoadDefaultConfig, then the web identity role and each of the assumed roles are
+// assumed in turn.
+func NewConfig(ctx context.Context, creds AwsCreds) (awsv2.Config, error) {
+	if err := creds.validate(); err != nil {
+		return awsv2.Config{}, err
+	}
+	var opts []func(*config.LoadOptions) error
+	if creds.Region != "" {
+		opts = append(opts, config.WithRegion(creds.Region))
+	}
+	if creds.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(creds.Profile))
+	}
+	if creds.AccessKeyID != "" {
+		provider := credentialsv2.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+		opts = append(opts, config.WithCredentialsProvider(provider))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return awsv2.Config{}, errors.Wrap(err, "error loading AWS config")
+	}
+
+	if creds.WebIdentityTokenFile != "" {
+		provider := stscredsv2.NewWebIdentityRoleProvider(creds.newSTSClient(cfg), creds.WebIdentityRoleARN,
+			stscredsv2.IdentityTokenFile(creds.WebIdentityTokenFile), func(o *stscredsv2.WebIdentityRoleOptions) {
+				o.RoleSessionName = DefaultRoleSessionName
+			})
+		cfg.Credentials = awsv2.NewCredentialsCache(provider)
+	}
+	for _, role := range creds.AssumeRoles {
+		role := role
+		provider := stscredsv2.NewAssumeRoleProvider(creds.newSTSClient(cfg), role.RoleARN, func(o *stscredsv2.AssumeRoleOptions) {
+			o.RoleSessionName = role.SessionName
+			if o.RoleSessionName == "" {
+				o.RoleSessionName = DefaultRoleSessionName
+			}
+			if role.ExternalID != "" {
+				o.ExternalID = awsv2.String(role.ExternalID)
+			}
+		})
+		cfg.Credentials = awsv2.NewCredentialsCache(provider)
+	}
+	return cfg, nil
+}
+
+// newSTSClient returns an STS client signing with the credentials of the config so far
+func (creds AwsCreds) newSTSClient(cfg awsv2.Config) *stsv2.Client {
+	return stsv2.NewFromConfig(cfg, func(o *stsv2.Options) {
+		if o.Region == "" {
+			o.Region = DefaultSTSRegion
+		}
+		if creds.STSEndpoint != "" {
+			o.BaseEndpoint = awsv2.String(creds.STSEndpoint)
+		}
+	})
+}
+
+// ConfigFromSession returns an aws-sdk-go-v2 config with the region, credentials and HTTP client of a v1 session, so
+// clients of the v2 SDK can be created from sessions returned by NewSession
+func ConfigFromSession(sess *session.Session) awsv2.Config {
+	cfg := awsv2.Config{}
+	if sess == nil {
+		return cfg
+	}
+	cfg.Region = aws.StringValue(sess.Config.Region)
+	if sess.Config.Credentials != nil {
+		cfg.Credentials = sessionCredentials{creds: sess.Config.Credentials}
+	}
+	if sess.Config.HTTPClient != nil {
+		cfg.HTTPClient = sess.Config.HTTPClient
+	}
+	return cfg
+}
+
+// sessionCredentials provides v2 credentials from v1 credentials, which cache and refresh themselves
+type sessionCredentials struct {
+	creds *credentials.Credentials
+}
+
+func (c sessionCredentials) Retrieve(ctx context.Context) (awsv2.Credentials, error) {
+	value, err := c.creds.GetWithContext(ctx)
+	if err != nil {
+		return awsv2.Credentials{}, err
+	}
+	result := awsv2.Credentials{
+		AccessKeyID:     value.AccessKeyID,
+		SecretAccessKey: value.SecretAccessKey,
+		SessionToken:    value.SessionToken,
+		Source:          value.ProviderName,
+	}
+	if expires, err := c.creds.ExpiresAt(); err == nil && !expires.IsZero() {
+		result.CanExpire = true
+		result.Expires = expires
+	}
+	return result, nil
+}
+
+// configCredentials provides v1 credentials from v2 credentials, which NewConfig caches, reporting the expiry of the
+// last credentials retrieved so the v1 credentials refresh them in time
+type configCredentials struct {
+	provider awsv2.CredentialsProvider
+	creds    awsv2.Credentials
+}
+
+func (c *configCredentials) Retrieve() (credentials.Value, error) {
+	return c.RetrieveWithContext(context.Background())
+}
+
+func (c *configCredentials) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	value, err := c.provider.Retrieve(ctx)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	c.creds = value
+	return credentials.Value{
+		AccessKeyID:     value.AccessKeyID,
+		SecretAccessKey: value.SecretAccessKey,
+		SessionToken:    value.SessionToken,
+		ProviderName:    value.Source,
+	}, nil
+}
+
+func (c *configCredentials) IsExpired() bool {
+	return c.creds.Expired()
+}
+
+func (c *configCredentials) ExpiresAt() time.Time {
+	if !c.creds.CanExpire {
+		return time.Time{}
+	}
+	return c.creds.Expires
+}
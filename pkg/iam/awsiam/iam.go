@@ -0,0 +1,124 @@
+package awsiam
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pkg/errors"
+)
+
+// DefaultRoleSessionName is the session name of assumed roles unless one is given
+const DefaultRoleSessionName = "secretfacade"
+
+// DefaultSTSRegion is the region of the STS calls made to assume roles when the session has no region, which is that
+// of the global STS endpoint
+const DefaultSTSRegion = "us-east-1"
+
+// AssumeRole is a role assumed with the credentials obtained so far
+type AssumeRole struct {
+	RoleARN string
+	// ExternalID is required by roles whose trust policy checks sts:ExternalId
+	ExternalID  string
+	SessionName string
+}
+
+// AwsCreds describes where the credentials of an AWS session come from. At most one of Profile, the static keys and
+// the web identity token file is used as the source credentials, falling back to the default credential chain. Each
+// of AssumeRoles is then assumed in turn, which allows chaining into a role in another account.
+type AwsCreds struct {
+	Region string
+	// Profile is a named profile of the shared config and credentials files
+	Profile string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// WebIdentityTokenFile is the token file, such as the one mounted by IAM roles for service accounts, exchanged for
+	// the credentials of WebIdentityRoleARN
+	WebIdentityTokenFile string
+	WebIdentityRoleARN   string
+
+	AssumeRoles []AssumeRole
+
+	// STSEndpoint overrides the endpoint of the STS calls made to assume roles
+	STSEndpoint string
+}
+
+// NewEnvironmentCreds reads credentials from environment variables with the prefix, e.g. AWS_SECRETSMANAGER_:
+// REGION, PROFILE, ACCESS_KEY_ID, SECRET_ACCESS_KEY, SESSION_TOKEN, WEB_IDENTITY_TOKEN_FILE, WEB_IDENTITY_ROLE_ARN,
+// ROLE_ARN, EXTERNAL_ID, ROLE_SESSION_NAME and STS_ENDPOINT. Unset variables leave the default credential chain,
+// which reads the standard AWS_ variables, in place.
+func NewEnvironmentCreds(prefix string) AwsCreds {
+	creds := AwsCreds{
+		Region:               os.Getenv(prefix + "REGION"),
+		Profile:              os.Getenv(prefix + "PROFILE"),
+		AccessKeyID:          os.Getenv(prefix + "ACCESS_KEY_ID"),
+		SecretAccessKey:      os.Getenv(prefix + "SECRET_ACCESS_KEY"),
+		SessionToken:         os.Getenv(prefix + "SESSION_TOKEN"),
+		WebIdentityTokenFile: os.Getenv(prefix + "WEB_IDENTITY_TOKEN_FILE"),
+		WebIdentityRoleARN:   os.Getenv(prefix + "WEB_IDENTITY_ROLE_ARN"),
+		STSEndpoint:          os.Getenv(prefix + "STS_ENDPOINT"),
+	}
+	if roleARN := os.Getenv(prefix + "ROLE_ARN"); roleARN != "" {
+		creds.AssumeRoles = []AssumeRole{{
+			RoleARN:     roleARN,
+			ExternalID:  os.Getenv(prefix + "EXTERNAL_ID"),
+			SessionName: os.Getenv(prefix + "ROLE_SESSION_NAME"),
+		}}
+	}
+	return creds
+}
+
+// NewSession returns a v1 SDK session with the region and credentials of the config returned by NewConfig, so both
+// SDKs assume the same roles.
+//
+// Deprecated: NewSession is kept for callers of the v1 SDK. Use NewConfig for the aws-sdk-go-v2 config used by the
+// secret managers.
+func NewSession(creds AwsCreds) (*session.Session, error) {
+	cfg, err := NewConfig(context.Background(), creds)
+	if err != nil {
+		return nil, err
+	}
+	opts := session.Options{}
+	if cfg.Region != "" {
+		opts.Config.Region = aws.String(cfg.Region)
+	}
+	if cfg.Credentials != nil {
+		opts.Config.Credentials = credentials.NewCredentials(&configCredentials{provider: cfg.Credentials})
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AWS session")
+	}
+	return sess, nil
+}
+
+// validate checks that the credentials have at most one source and everything that source and the roles need
+func (creds AwsCreds) validate() error {
+	sources := 0
+	for _, set := range []bool{creds.Profile != "", creds.AccessKeyID != "", creds.WebIdentityTokenFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("only one of a profile, static keys and a web identity token file can be used as AWS credentials")
+	}
+	if (creds.AccessKeyID == "") != (creds.SecretAccessKey == "") {
+		return fmt.Errorf("both the access key ID and secret access key are needed for static AWS credentials")
+	}
+	if creds.WebIdentityTokenFile != "" && creds.WebIdentityRoleARN == "" {
+		return fmt.Errorf("the role ARN is needed to use a web identity token file as AWS credentials")
+	}
+	for _, role := range creds.AssumeRoles {
+		if role.RoleARN == "" {
+			return fmt.Errorf("the ARN of an assumed AWS role is missing")
+		}
+	}
+	return nil
+}
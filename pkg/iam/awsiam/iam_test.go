@@ -0,0 +1,228 @@
+package awsiam_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/awsiam"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fakeaws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticCreds(t *testing.T) {
+	sess, err := awsiam.NewSession(awsiam.AwsCreds{Region: "eu-west-1", AccessKeyID: "AKIATEST", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	value, err := sess.Config.Credentials.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "AKIATEST", value.AccessKeyID)
+	assert.Equal(t, "eu-west-1", *sess.Config.Region)
+}
+
+func TestAssumeRoleChain(t *testing.T) {
+	server := fakeaws.NewSTSServer()
+	t.Cleanup(server.Close)
+
+	sess, err := awsiam.NewSession(awsiam.AwsCreds{
+		Region:          "eu-west-1",
+		AccessKeyID:     "AKIATEST",
+		SecretAccessKey: "secret",
+		AssumeRoles: []awsiam.AssumeRole{
+			{RoleARN: "arn:aws:iam::111111111111:role/hop"},
+			{RoleARN: "arn:aws:iam::222222222222:role/secrets", ExternalID: "central", SessionName: "sync"},
+		},
+		STSEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+	value, err := sess.Config.Credentials.Get()
+	require.NoError(t, err)
+
+	calls := server.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/hop", calls[0].RoleARN)
+	assert.Equal(t, awsiam.DefaultRoleSessionName, calls[0].RoleSessionName)
+	assert.Equal(t, "AKIATEST", calls[0].AccessKeyID)
+	assert.Equal(t, "arn:aws:iam::222222222222:role/secrets", calls[1].RoleARN)
+	assert.Equal(t, "central", calls[1].ExternalID)
+	assert.Equal(t, "sync", calls[1].RoleSessionName)
+	assert.Equal(t, calls[0].IssuedAccessKeyID, calls[1].AccessKeyID)
+	assert.Equal(t, calls[1].IssuedAccessKeyID, value.AccessKeyID)
+}
+
+func TestWebIdentity(t *testing.T) {
+	server := fakeaws.NewSTSServer()
+	t.Cleanup(server.Close)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("header.payload.signature"), 0o600))
+
+	sess, err := awsiam.NewSession(awsiam.AwsCreds{
+		Region:               "eu-west-1",
+		WebIdentityTokenFile: tokenFile,
+		WebIdentityRoleARN:   "arn:aws:iam::111111111111:role/irsa",
+		AssumeRoles:          []awsiam.AssumeRole{{RoleARN: "arn:aws:iam::222222222222:role/secrets"}},
+		STSEndpoint:          server.URL,
+	})
+	require.NoError(t, err)
+	value, err := sess.Config.Credentials.Get()
+	require.NoError(t, err)
+
+	calls := server.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "AssumeRoleWithWebIdentity", calls[0].Action)
+	assert.Equal(t, "header.payload.signature", calls[0].WebIdentityToken)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/irsa", calls[0].RoleARN)
+	assert.Equal(t, "AssumeRole", calls[1].Action)
+	assert.Equal(t, calls[0].IssuedAccessKeyID, calls[1].AccessKeyID)
+	assert.Equal(t, calls[1].IssuedAccessKeyID, value.AccessKeyID)
+}
+
+func TestInvalidCreds(t *testing.T) {
+	testCases := map[string]awsiam.AwsCreds{
+		"profile and static keys":      {Profile: "dev", AccessKeyID: "AKIATEST", SecretAccessKey: "secret"},
+		"access key without secret":    {AccessKeyID: "AKIATEST"},
+		"web identity without role":    {WebIdentityTokenFile: "/var/run/token"},
+		"assumed role without the ARN": {AssumeRoles: []awsiam.AssumeRole{{ExternalID: "central"}}},
+	}
+	for name, creds := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := awsiam.NewSession(creds)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewEnvironmentCreds(t *testing.T) {
+	t.Setenv("AWS_SSM_REGION", "eu-west-1")
+	t.Setenv("AWS_SSM_PROFILE", "central")
+	t.Setenv("AWS_SSM_ROLE_ARN", "arn:aws:iam::222222222222:role/secrets")
+	t.Setenv("AWS_SSM_EXTERNAL_ID", "central")
+
+	creds := awsiam.NewEnvironmentCreds("AWS_SSM_")
+	assert.Equal(t, "eu-west-1", creds.Region)
+	assert.Equal(t, "central", creds.Profile)
+	assert.Equal(t, []awsiam.AssumeRole{{RoleARN: "arn:aws:iam::222222222222:role/secrets", ExternalID: "central"}}, creds.AssumeRoles)
+}
+
+func TestAssumeRoleWithoutRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	server := fakeaws.NewSTSServer()
+	t.Cleanup(server.Close)
+
+	sess, err := awsiam.NewSession(awsiam.AwsCreds{
+		AccessKeyID:     "AKIATEST",
+		SecretAccessKey: "secret",
+		AssumeRoles:     []awsiam.AssumeRole{{RoleARN: "arn:aws:iam::111111111111:role/secrets"}},
+		STSEndpoint:     server.URL,
+	})
+	require.NoError(t, err)
+	value, err := sess.Config.Credentials.Get()
+	require.NoError(t, err)
+
+	calls := server.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, calls[0].IssuedAccessKeyID, value.AccessKeyID)
+}
+
+func TestConfigAssumeRoleChain(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	server := fakeaws.NewSTSServer()
+	t.Cleanup(server.Close)
+
+	cfg, err := awsiam.NewConfig(context.Background(), awsiam.AwsCreds{
+		Region:          "eu-west-1",
+		AccessKeyID:     "AKIATEST",
+		SecretAccessKey: "secret",
+		AssumeRoles: []awsiam.AssumeRole{
+			{RoleARN: "arn:aws:iam::111111111111:role/hop"},
+			{RoleARN: "arn:aws:iam::222222222222:role/secrets", ExternalID: "central", SessionName: "sync"},
+		},
+		STSEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", cfg.Region)
+	value, err := cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+
+	calls := server.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/hop", calls[0].RoleARN)
+	assert.Equal(t, awsiam.DefaultRoleSessionName, calls[0].RoleSessionName)
+	assert.Equal(t, "AKIATEST", calls[0].AccessKeyID)
+	assert.Equal(t, "central", calls[1].ExternalID)
+	assert.Equal(t, "sync", calls[1].RoleSessionName)
+	assert.Equal(t, calls[0].IssuedAccessKeyID, calls[1].AccessKeyID)
+	assert.Equal(t, calls[1].IssuedAccessKeyID, value.AccessKeyID)
+}
+
+func TestConfigWebIdentityWithoutRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	server := fakeaws.NewSTSServer()
+	t.Cleanup(server.Close)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("header.payload.signature"), 0o600))
+
+	cfg, err := awsiam.NewConfig(context.Background(), awsiam.AwsCreds{
+		WebIdentityTokenFile: tokenFile,
+		WebIdentityRoleARN:   "arn:aws:iam::111111111111:role/irsa",
+		STSEndpoint:          server.URL,
+	})
+	require.NoError(t, err)
+	value, err := cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+
+	calls := server.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "AssumeRoleWithWebIdentity", calls[0].Action)
+	assert.Equal(t, "header.payload.signature", calls[0].WebIdentityToken)
+	assert.Equal(t, calls[0].IssuedAccessKeyID, value.AccessKeyID)
+
+	_, err = awsiam.NewConfig(context.Background(), awsiam.AwsCreds{AccessKeyID: "AKIATEST"})
+	assert.Error(t, err)
+}
+
+func TestSessionMatchesConfig(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("header.payload.signature"), 0o600))
+	newCreds := func(server *fakeaws.STSServer) awsiam.AwsCreds {
+		return awsiam.AwsCreds{
+			Region:               "eu-west-1",
+			WebIdentityTokenFile: tokenFile,
+			WebIdentityRoleARN:   "arn:aws:iam::111111111111:role/irsa",
+			AssumeRoles:          []awsiam.AssumeRole{{RoleARN: "arn:aws:iam::222222222222:role/secrets", ExternalID: "central"}},
+			STSEndpoint:          server.URL,
+		}
+	}
+
+	configServer := fakeaws.NewSTSServer()
+	t.Cleanup(configServer.Close)
+	cfg, err := awsiam.NewConfig(context.Background(), newCreds(configServer))
+	require.NoError(t, err)
+	expected, err := cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+
+	// the secret managers created from a v1 session use the config converted from it
+	sessionServer := fakeaws.NewSTSServer()
+	t.Cleanup(sessionServer.Close)
+	sess, err := awsiam.NewSession(newCreds(sessionServer))
+	require.NoError(t, err)
+	sessionCfg := awsiam.ConfigFromSession(sess)
+	actual, err := sessionCfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, cfg.Region, sessionCfg.Region)
+	assert.Equal(t, configServer.Calls(), sessionServer.Calls())
+	assert.Equal(t, expected.AccessKeyID, actual.AccessKeyID)
+	assert.Equal(t, expected.SecretAccessKey, actual.SecretAccessKey)
+	assert.Equal(t, expected.SessionToken, actual.SessionToken)
+	assert.Equal(t, expected.Expires, actual.Expires)
+}
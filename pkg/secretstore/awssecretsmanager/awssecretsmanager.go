@@ -1,28 +1,101 @@
 package awssecretsmanager
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"sync"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/smithy-go"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/awsiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
 	"github.com/pkg/errors"
 )
 
-func NewAwsSecretManager(session *session.Session) secretstore.Interface {
-	return awsSecretsManager{session}
+// NewAwsSecretManager returns a secret manager using the region, credentials and HTTP client of a v1 SDK session.
+//
+// Deprecated: NewAwsSecretManager is kept for callers of the v1 SDK. Use NewAwsSecretManagerFromConfig.
+func NewAwsSecretManager(session *session.Session, opts ...Option) secretstore.Interface {
+	return NewAwsSecretManagerFromConfig(awsiam.ConfigFromSession(session), opts...)
+}
+
+// NewAwsSecretManagerFromConfig returns a secret manager using an aws-sdk-go-v2 config. The region of the config is
+// replaced by the location of each call.
+func NewAwsSecretManagerFromConfig(config aws.Config, opts ...Option) secretstore.Interface {
+	a := &awsSecretsManager{config: config, clients: &clientCache{clients: map[string]*secretsmanager.Client{}}}
+	for _, o := range opts {
+		o(a)
+	}
+	return *a
 }
 
 type awsSecretsManager struct {
-	session *session.Session
+	config         aws.Config
+	clients        *clientCache
+	endpoint       string
+	httpClient     *http.Client
+	kmsKeyID       string
+	replicaRegions []ReplicaRegion
+	conversion     ConversionPolicy
+	wrapKey        string
+}
+
+// clientCache holds a client per region, as clients are safe for concurrent use
+type clientCache struct {
+	lock    sync.Mutex
+	clients map[string]*secretsmanager.Client
+}
+
+// Option configures an AWS Secrets Manager secret manager
+type Option func(*awsSecretsManager)
+
+// WithEndpoint overrides the Secrets Manager endpoint of every region, e.g. to use a VPC endpoint or a local fake
+func WithEndpoint(endpoint string) Option {
+	return func(a *awsSecretsManager) {
+		a.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to call Secrets Manager
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *awsSecretsManager) {
+		a.httpClient = client
+	}
+}
+
+// client returns the Secrets Manager client of the region, creating it on first use
+func (a awsSecretsManager) client(location string) *secretsmanager.Client {
+	a.clients.lock.Lock()
+	defer a.clients.lock.Unlock()
+	if c, ok := a.clients.clients[location]; ok {
+		return c
+	}
+	c := secretsmanager.NewFromConfig(a.config, func(o *secretsmanager.Options) {
+		o.Region = location
+		if a.endpoint != "" {
+			o.BaseEndpoint = aws.String(a.endpoint)
+		}
+		if a.httpClient != nil {
+			o.HTTPClient = a.httpClient
+		}
+	})
+	a.clients.clients[location] = c
+	return c
 }
 
 func (a awsSecretsManager) GetSecret(location, secretName, propertyName string) (string, error) {
-	secret, err := getExistingSecret(a.session, location, secretName)
+	return a.GetSecretWithContext(context.TODO(), location, secretName, propertyName)
+}
+
+// GetSecretWithContext reads a secret, or a property of it, with the context of the request
+func (a awsSecretsManager) GetSecretWithContext(ctx context.Context, location, secretName, propertyName string) (string, error) {
+	secret, err := a.getExistingSecret(ctx, location, secretName)
 	if err != nil {
-		return "", errors.Wrap(err, "error retrieving existing secret for aws secret manager: ")
+		return "", errors.Wrap(notFoundError(err, location, secretName), "error retrieving existing secret for aws secret manager: ")
 	}
 
 	if propertyName != "" {
@@ -33,7 +106,15 @@ func (a awsSecretsManager) GetSecret(location, secretName, propertyName string)
 		return secretString, nil
 	}
 
-	return *secret.SecretString, nil
+	return secretString(secret.SecretString, secret.SecretBinary), nil
+}
+
+// secretString returns the value of a text secret, or the bytes of a binary secret which []byte(value) restores
+func secretString(text *string, binary []byte) string {
+	if text == nil && binary != nil {
+		return string(binary)
+	}
+	return aws.ToString(text)
 }
 
 func getSecretProperty(s *secretsmanager.GetSecretValueOutput, propertyName string) (string, error) {
@@ -44,33 +125,32 @@ func getSecretProperty(s *secretsmanager.GetSecretValueOutput, propertyName stri
 	return m[propertyName], nil
 }
 
-func (a awsSecretsManager) SetSecret(location, secretName string, secretValue *secretstore.SecretValue) (err error) {
+func (a awsSecretsManager) SetSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
+	return a.SetSecretWithContext(context.TODO(), location, secretName, secretValue)
+}
+
+// SetSecretWithContext creates or updates a secret with the context of the requests
+func (a awsSecretsManager) SetSecretWithContext(ctx context.Context, location, secretName string, secretValue *secretstore.SecretValue) (err error) {
 	// CreateSecret
-	err = createSecret(a.session, location, secretName, *secretValue)
+	err = a.createSecret(ctx, location, secretName, *secretValue)
 	if err != nil {
 		// Don't return if secret already exists.
-		if err.(awserr.Error).Code() != secretsmanager.ErrCodeResourceExistsException {
+		if !isAlreadyExists(err) {
 			return errors.Wrap(err, "error creating new secret for aws secret manager: ")
 		}
+		err = a.updateSecretMetadata(ctx, location, secretName, secretValue)
+		if err != nil {
+			return errors.Wrap(err, "error updating existing secret for aws secret manager: ")
+		}
 	}
 
 	// GetSecretValue + PutSecretValue/UpdateSecret
 	// Get, Merge and Update
-	secret, err := getExistingSecret(a.session, location, secretName)
+	secret, err := a.getExistingSecret(ctx, location, secretName)
 	if err != nil {
 		return errors.Wrap(err, "error retreiving existing secret for aws secret manager: ")
 	}
-	var existingSecretProps map[string]string
-	// FIXME: If secretValue is Simple, AND then secret.SecretString is Simple.
-	// getSecretPropertyMap fails
-	if secretValue.Value == "" && secretValue.PropertyValues != nil {
-		existingSecretProps, err = getSecretPropertyMap(secret.SecretString)
-		if err != nil {
-			return errors.Wrap(err, "error parsing existing secret: ")
-		}
-	}
-
-	err = updateSecret(a.session, secret, secretValue.MergeExistingSecret(existingSecretProps), location)
+	err = a.updateSecret(ctx, location, secretName, secret, secretValue)
 	if err != nil {
 		return errors.Wrap(err, "error updating existing secret for aws secret manager: ")
 	}
@@ -78,49 +158,107 @@ func (a awsSecretsManager) SetSecret(location, secretName string, secretValue *s
 	return nil
 }
 
-func updateSecret(session *session.Session, secret *secretsmanager.GetSecretValueOutput, newValue, location string) (err error) {
+// CreateSecret creates a secret, returning a secretstore.ConflictError if it already exists
+func (a awsSecretsManager) CreateSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
+	err := a.createSecret(context.TODO(), location, secretName, *secretValue)
+	if isAlreadyExists(err) {
+		return &secretstore.ConflictError{Location: location, SecretName: secretName, Err: err}
+	}
+	if err != nil {
+		return errors.Wrap(err, "error creating new secret for aws secret manager: ")
+	}
+	return nil
+}
+
+func (a awsSecretsManager) updateSecret(ctx context.Context, location, secretName string, secret *secretsmanager.GetSecretValueOutput, secretValue *secretstore.SecretValue) (err error) {
 	input := &secretsmanager.PutSecretValueInput{
-		SecretId:     secret.ARN,
-		SecretString: aws.String(newValue),
+		SecretId: secret.ARN,
 	}
-	svc := secretsmanager.New(session, aws.NewConfig().WithRegion(location))
-	_, err = svc.PutSecretValue(input)
+	if secretValue.BinaryValue != nil {
+		input.SecretBinary = secretValue.BinaryValue
+	} else {
+		value, err := a.mergeSecretString(secretName, secret, secretValue)
+		if err != nil {
+			return err
+		}
+		input.SecretString = aws.String(value)
+	}
+	_, err = a.client(location).PutSecretValue(ctx, input)
 	if err != nil {
 		return errors.Wrap(err, "error updating existing secret: ")
 	}
 	return nil
 }
 
-func getExistingSecret(session *session.Session, location, secretName string) (secret *secretsmanager.GetSecretValueOutput, err error) {
+func (a awsSecretsManager) getExistingSecret(ctx context.Context, location, secretName string) (secret *secretsmanager.GetSecretValueOutput, err error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId: &secretName,
 	}
-	svc := secretsmanager.New(session, aws.NewConfig().WithRegion(location))
-	secret, err = svc.GetSecretValue(input)
-	if err != nil {
-		return
-	}
-	return
+	return a.client(location).GetSecretValue(ctx, input)
 }
 
-func createSecret(session *session.Session, location, secretName string, secretValue secretstore.SecretValue) (err error) {
-	input := &secretsmanager.CreateSecretInput{
-		Name:         &secretName,
-		SecretString: aws.String(secretValue.ToString()),
+func (a awsSecretsManager) createSecret(ctx context.Context, location, secretName string, secretValue secretstore.SecretValue) (err error) {
+	input := a.createSecretInput(location, secretName, &secretValue)
+	if secretValue.BinaryValue != nil {
+		input.SecretBinary = secretValue.BinaryValue
+	} else {
+		input.SecretString = aws.String(secretValue.ToString())
 	}
-	svc := secretsmanager.New(session, aws.NewConfig().WithRegion(location))
-	_, err = svc.CreateSecret(input)
+	_, err = a.client(location).CreateSecret(ctx, input)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// createSecretInput returns the input to create a secret in location, without a value, with the tags, description,
+// KMS key and replicas of the secret value
+func (a awsSecretsManager) createSecretInput(location, secretName string, secretValue *secretstore.SecretValue) *secretsmanager.CreateSecretInput {
+	input := &secretsmanager.CreateSecretInput{
+		Name:              aws.String(secretName),
+		Tags:              labelsToTags(secretValue.Labels),
+		AddReplicaRegions: a.addRegions(location),
+	}
+	if description := annotationsToDescription(secretValue.Annotations); description != "" {
+		input.Description = aws.String(description)
+	}
+	if a.kmsKeyID != "" {
+		input.KmsKeyId = aws.String(a.kmsKeyID)
+	}
+	return input
+}
+
+// notFoundError converts the error of a call about a secret that doesn't exist to a secretstore.NotFoundError, and
+// returns other errors unchanged. Errors of batch calls aren't typed so are checked by their code.
+func notFoundError(err error, location, secretName string) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceNotFoundException" {
+		return &secretstore.NotFoundError{Location: location, SecretName: secretName, Err: err}
+	}
+	return err
+}
+
+func isAlreadyExists(err error) bool {
+	var exists *types.ResourceExistsException
+	return errors.As(err, &exists)
+}
+
+// getSecretPropertyMap parses the properties of a secret, returning values that aren't strings as JSON text
 func getSecretPropertyMap(value *string) (map[string]string, error) {
-	m := make(map[string]string)
-	err := json.Unmarshal([]byte(*value), &m)
-	if err != nil {
-		return nil, errors.Wrap(err, "error unmarshalling AWS secrets manager secret payload in to map[string]string")
+	if value == nil {
+		return nil, errors.New("secret is binary so has no properties")
+	}
+	properties, ok := parseProperties(*value)
+	if !ok {
+		return nil, errors.New("error unmarshalling AWS secrets manager secret payload in to a JSON object")
+	}
+	m := make(map[string]string, len(properties))
+	for k, raw := range properties {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		m[k] = s
 	}
 	return m, nil
 }
@@ -0,0 +1,33 @@
+package awssecretsmanager
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretString(t *testing.T) {
+	keytab := []byte{0x05, 0x02, 0x00, 0xff}
+	assert.Equal(t, keytab, []byte(secretString(nil, keytab)))
+	assert.Equal(t, "text", secretString(aws.String("text"), nil))
+	assert.Equal(t, "", secretString(nil, nil))
+
+	_, err := getSecretPropertyMap(nil)
+	assert.Error(t, err)
+}
+
+func TestAddRegionsSkipsPrimaryRegion(t *testing.T) {
+	mgr := NewAwsSecretManager(nil, WithReplicaRegions(
+		ReplicaRegion{Region: "eu-west-1"},
+		ReplicaRegion{Region: "us-east-1", KMSKeyID: "alias/secrets"},
+		ReplicaRegion{Region: "ap-southeast-2"},
+	)).(awsSecretsManager)
+
+	regions := mgr.addRegions("eu-west-1")
+	assert.Len(t, regions, 2)
+	assert.Equal(t, "us-east-1", aws.ToString(regions[0].Region))
+	assert.Equal(t, "alias/secrets", aws.ToString(regions[0].KmsKeyId))
+	assert.Equal(t, "ap-southeast-2", aws.ToString(regions[1].Region))
+	assert.Nil(t, regions[1].KmsKeyId)
+}
@@ -0,0 +1,129 @@
+package awssecretsmanager
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// maxBatchGetSecrets is the maximum number of secret ids accepted by a single BatchGetSecretValue call
+const maxBatchGetSecrets = 20
+
+// GetSecrets reads the secrets of each region with BatchGetSecretValue, 20 secrets per call, making at most
+// concurrency calls at once
+func (a awsSecretsManager) GetSecrets(refs []secretstore.SecretRef, concurrency int) []secretstore.GetResult {
+	return a.GetSecretsWithContext(context.TODO(), refs, concurrency)
+}
+
+// GetSecretsWithContext reads the secrets like GetSecrets with the context of the requests
+func (a awsSecretsManager) GetSecretsWithContext(ctx context.Context, refs []secretstore.SecretRef, concurrency int) []secretstore.GetResult {
+	results := make([]secretstore.GetResult, len(refs))
+	for i, ref := range refs {
+		results[i].Ref = ref
+	}
+
+	// each chunk is read by a single call
+	type chunk struct {
+		location string
+		ids      []string
+		secrets  map[string]types.SecretValueEntry
+		errs     map[string]error
+	}
+	var chunks []*chunk
+	chunkOf := map[string]*chunk{}
+	locations, byLocation := secretstore.GroupRefsByLocation(refs)
+	for _, location := range locations {
+		var c *chunk
+		for _, i := range byLocation[location] {
+			key := location + "/" + refs[i].SecretName
+			if _, ok := chunkOf[key]; ok {
+				continue
+			}
+			if c == nil || len(c.ids) == maxBatchGetSecrets {
+				c = &chunk{location: location}
+				chunks = append(chunks, c)
+			}
+			c.ids = append(c.ids, refs[i].SecretName)
+			chunkOf[key] = c
+		}
+	}
+	secretstore.Parallel(len(chunks), concurrency, func(i int) {
+		c := chunks[i]
+		c.secrets, c.errs = a.batchGetSecretValues(ctx, c.location, c.ids)
+	})
+
+	for i, ref := range refs {
+		c := chunkOf[ref.Location+"/"+ref.SecretName]
+		if err, ok := c.errs[ref.SecretName]; ok {
+			results[i].Err = errors.Wrapf(notFoundError(err, ref.Location, ref.SecretName), "error retrieving secret %s from aws secret manager", ref.SecretName)
+			continue
+		}
+		secret, ok := c.secrets[ref.SecretName]
+		if !ok {
+			results[i].Err = &secretstore.NotFoundError{Location: ref.Location, SecretName: ref.SecretName}
+			continue
+		}
+		if ref.SecretKey == "" {
+			results[i].Value = secretString(secret.SecretString, secret.SecretBinary)
+			continue
+		}
+		m, err := getSecretPropertyMap(secret.SecretString)
+		if err != nil {
+			results[i].Err = errors.Wrapf(err, "error reading property %s from secret JSON object", ref.SecretKey)
+			continue
+		}
+		results[i].Value = m[ref.SecretKey]
+	}
+	return results
+}
+
+// SetSecrets writes the secrets from a pool of workers as Secrets Manager has no batch write
+func (a awsSecretsManager) SetSecrets(writes []secretstore.SecretWrite, concurrency int) []secretstore.SetResult {
+	return a.SetSecretsWithContext(context.TODO(), writes, concurrency)
+}
+
+// SetSecretsWithContext writes the secrets like SetSecrets with the context of the requests
+func (a awsSecretsManager) SetSecretsWithContext(ctx context.Context, writes []secretstore.SecretWrite, concurrency int) []secretstore.SetResult {
+	return secretstore.ParallelSetSecretsWithContext(ctx, a, writes, concurrency)
+}
+
+// batchGetSecretValues reads up to maxBatchGetSecrets secrets and returns those found and the errors for those that
+// could not be read, both keyed by the requested secret id
+func (a awsSecretsManager) batchGetSecretValues(ctx context.Context, location string, ids []string) (map[string]types.SecretValueEntry, map[string]error) {
+	secrets := map[string]types.SecretValueEntry{}
+	errs := map[string]error{}
+	requested := map[string]bool{}
+	for _, id := range ids {
+		requested[id] = true
+	}
+
+	pages := secretsmanager.NewBatchGetSecretValuePaginator(a.client(location), &secretsmanager.BatchGetSecretValueInput{SecretIdList: ids})
+	for pages.HasMorePages() {
+		output, err := pages.NextPage(ctx)
+		if err != nil {
+			for _, id := range ids {
+				if _, ok := secrets[id]; !ok {
+					errs[id] = err
+				}
+			}
+			break
+		}
+		for _, v := range output.SecretValues {
+			// secrets may be requested by name or ARN
+			for _, id := range []string{aws.ToString(v.Name), aws.ToString(v.ARN)} {
+				if requested[id] {
+					secrets[id] = v
+				}
+			}
+		}
+		for _, e := range output.Errors {
+			errs[aws.ToString(e.SecretId)] = &smithy.GenericAPIError{Code: aws.ToString(e.ErrorCode), Message: aws.ToString(e.Message)}
+		}
+	}
+	return secrets, errs
+}
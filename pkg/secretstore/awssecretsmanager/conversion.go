@@ -0,0 +1,100 @@
+package awssecretsmanager
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// ConversionPolicy decides what happens when property values are written to an existing secret whose value isn't a
+// JSON object, so there are no properties to merge them with
+type ConversionPolicy string
+
+const (
+	// ConversionError fails the write, leaving the existing value alone. It is the default.
+	ConversionError ConversionPolicy = "error"
+	// ConversionOverwrite replaces the existing value with the property values
+	ConversionOverwrite ConversionPolicy = "overwrite"
+	// ConversionWrap keeps the existing value as a property under the wrap key and merges the property values with
+	// it. Binary secrets can't be wrapped so fail as with ConversionError.
+	ConversionWrap ConversionPolicy = "wrap"
+)
+
+// DefaultWrapKey is the property the existing value is kept under by ConversionWrap unless WithWrapKey is used
+const DefaultWrapKey = "value"
+
+// WithConversionPolicy sets what happens when property values are written to an existing secret that isn't a JSON
+// object. SecretValue.Overwrite skips the merge, and so the conversion, altogether.
+func WithConversionPolicy(policy ConversionPolicy) Option {
+	return func(a *awsSecretsManager) {
+		a.conversion = policy
+	}
+}
+
+// WithWrapKey sets the property the existing value is kept under by ConversionWrap
+func WithWrapKey(key string) Option {
+	return func(a *awsSecretsManager) {
+		a.wrapKey = key
+	}
+}
+
+// mergeSecretString returns the text to write for a secret value given the existing version of the secret, if any.
+// Property values are merged with the properties of the existing version unless the secret value is a simple one or
+// sets Overwrite, in which case it replaces the existing version.
+func (a awsSecretsManager) mergeSecretString(secretName string, existing *secretsmanager.GetSecretValueOutput, secretValue *secretstore.SecretValue) (string, error) {
+	if existing == nil || secretValue.Overwrite || secretValue.Value != "" || secretValue.PropertyValues == nil {
+		return secretValue.ToString(), nil
+	}
+
+	if existing.SecretString == nil {
+		if a.conversion != ConversionOverwrite {
+			return "", errors.Errorf("existing secret %s is binary so property values can't be merged into it", secretName)
+		}
+		return secretValue.ToString(), nil
+	}
+	properties, ok := parseProperties(*existing.SecretString)
+	if !ok {
+		switch a.conversion {
+		case ConversionOverwrite:
+			return secretValue.ToString(), nil
+		case ConversionWrap:
+			key := a.wrapKey
+			if key == "" {
+				key = DefaultWrapKey
+			}
+			properties = map[string]json.RawMessage{key: marshalString(*existing.SecretString)}
+		default:
+			return "", errors.Errorf("existing secret %s is not a JSON object so property values can't be merged into it, "+
+				"set Overwrite or use a conversion policy to replace or wrap the existing value", secretName)
+		}
+	}
+
+	for k, v := range secretValue.PropertyValues {
+		properties[k] = marshalString(v)
+	}
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return "", errors.Wrapf(err, "error marshalling merged properties of secret %s", secretName)
+	}
+	return string(data), nil
+}
+
+// parseProperties parses a JSON object, keeping its values as they are so properties written by other tools that
+// aren't strings survive a merge. An empty value has no properties.
+func parseProperties(value string) (map[string]json.RawMessage, bool) {
+	if value == "" {
+		return map[string]json.RawMessage{}, true
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &properties); err != nil || properties == nil {
+		return nil, false
+	}
+	return properties, true
+}
+
+func marshalString(s string) json.RawMessage {
+	data, _ := json.Marshal(s)
+	return data
+}
@@ -0,0 +1,68 @@
+package awssecretsmanager
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeSecretString(t *testing.T) {
+	properties := &secretstore.SecretValue{PropertyValues: map[string]string{"password": "new"}}
+	simple := &secretsmanager.GetSecretValueOutput{SecretString: aws.String("old")}
+	mixed := &secretsmanager.GetSecretValueOutput{SecretString: aws.String(`{"password":"old","port":5432,"tls":{"enabled":true}}`)}
+	binary := &secretsmanager.GetSecretValueOutput{SecretBinary: []byte{0x00, 0xff}}
+
+	testCases := []struct {
+		name        string
+		opts        []Option
+		existing    *secretsmanager.GetSecretValueOutput
+		secretValue *secretstore.SecretValue
+		expected    string
+		expectError bool
+	}{
+		{name: "new secret", secretValue: properties, expected: `{"password":"new"}`},
+		{name: "simple value replaces properties", existing: mixed, secretValue: &secretstore.SecretValue{Value: "simple"}, expected: "simple"},
+		{name: "merge keeps values that aren't strings", existing: mixed, secretValue: properties,
+			expected: `{"password":"new","port":5432,"tls":{"enabled":true}}`},
+		{name: "overwrite skips merge", existing: mixed, secretValue: &secretstore.SecretValue{PropertyValues: map[string]string{"password": "new"}, Overwrite: true},
+			expected: `{"password":"new"}`},
+		{name: "simple existing fails by default", existing: simple, secretValue: properties, expectError: true},
+		{name: "simple existing overwritten", opts: []Option{WithConversionPolicy(ConversionOverwrite)}, existing: simple, secretValue: properties,
+			expected: `{"password":"new"}`},
+		{name: "simple existing wrapped", opts: []Option{WithConversionPolicy(ConversionWrap)}, existing: simple, secretValue: properties,
+			expected: `{"password":"new","value":"old"}`},
+		{name: "simple existing wrapped under key", opts: []Option{WithConversionPolicy(ConversionWrap), WithWrapKey("legacy")}, existing: simple,
+			secretValue: properties, expected: `{"legacy":"old","password":"new"}`},
+		{name: "binary existing can't be wrapped", opts: []Option{WithConversionPolicy(ConversionWrap)}, existing: binary, secretValue: properties,
+			expectError: true},
+		{name: "binary existing overwritten", opts: []Option{WithConversionPolicy(ConversionOverwrite)}, existing: binary, secretValue: properties,
+			expected: `{"password":"new"}`},
+		{name: "empty existing", existing: &secretsmanager.GetSecretValueOutput{SecretString: aws.String("")}, secretValue: properties,
+			expected: `{"password":"new"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mgr := NewAwsSecretManager(nil, tc.opts...).(awsSecretsManager)
+			value, err := mgr.mergeSecretString("db", tc.existing, tc.secretValue)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestGetSecretPropertyMapWithMixedValues(t *testing.T) {
+	m, err := getSecretPropertyMap(aws.String(`{"user":"admin","port":5432,"tls":true}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"user": "admin", "port": "5432", "tls": "true"}, m)
+
+	_, err = getSecretPropertyMap(aws.String("simple"))
+	assert.Error(t, err)
+}
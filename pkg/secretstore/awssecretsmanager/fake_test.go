@@ -0,0 +1,262 @@
+package awssecretsmanager_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/awssecretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fakeaws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegion = "eu-west-1"
+
+func newFakeSecretManager(t *testing.T, opts ...awssecretsmanager.Option) secretstore.Interface {
+	server := fakeaws.NewSecretsManagerServer()
+	t.Cleanup(server.Close)
+	opts = append([]awssecretsmanager.Option{
+		awssecretsmanager.WithEndpoint(server.URL),
+		awssecretsmanager.WithHTTPClient(server.Client()),
+	}, opts...)
+	return awssecretsmanager.NewAwsSecretManagerFromConfig(fakeaws.NewConfig(), opts...)
+}
+
+func TestFakeSetAndGetSecret(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "token", &secretstore.SecretValue{Value: "first"}))
+	require.NoError(t, mgr.SetSecret(testRegion, "token", &secretstore.SecretValue{Value: "second"}))
+	value, err := mgr.GetSecret(testRegion, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "second", value)
+
+	_, err = mgr.GetSecret("us-west-2", "token", "")
+	require.Error(t, err)
+	assert.True(t, secretstore.IsNotFound(err))
+}
+
+func TestFakeMergesPropertyValues(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"username": "admin", "password": "first"},
+	}))
+	require.NoError(t, mgr.SetSecret(testRegion, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "second"},
+	}))
+
+	username, err := mgr.GetSecret(testRegion, "db", "username")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+	password, err := mgr.GetSecret(testRegion, "db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "second", password)
+}
+
+func TestFakeBinarySecret(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+	keytab := []byte{0x05, 0x02, 0x00, 0xff, 0x10}
+
+	require.NoError(t, mgr.SetSecret(testRegion, "keytab", &secretstore.SecretValue{BinaryValue: keytab}))
+	value, err := mgr.GetSecret(testRegion, "keytab", "")
+	require.NoError(t, err)
+	assert.Equal(t, keytab, []byte(value))
+
+	_, err = mgr.GetSecret(testRegion, "keytab", "principal")
+	assert.Error(t, err)
+}
+
+func TestFakeSecretWithContext(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+	ctxMgr, ok := mgr.(secretstore.ContextInterface)
+	require.True(t, ok)
+
+	ctx := context.Background()
+	require.NoError(t, ctxMgr.SetSecretWithContext(ctx, testRegion, "token", &secretstore.SecretValue{Value: "first"}))
+	value, err := ctxMgr.GetSecretWithContext(ctx, testRegion, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = ctxMgr.SetSecretWithContext(cancelled, testRegion, "token", &secretstore.SecretValue{Value: "second"})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = ctxMgr.GetSecretWithContext(cancelled, testRegion, "token", "")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	results := secretstore.GetSecretsWithContext(cancelled, mgr, []secretstore.SecretRef{{Location: testRegion, SecretName: "token"}}, 1)
+	assert.ErrorIs(t, results[0].Err, context.Canceled)
+	stages := mgr.(awssecretsmanager.StageInterface)
+	_, err = stages.SetPendingSecretWithContext(cancelled, testRegion, "token", &secretstore.SecretValue{Value: "pending"})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = stages.GetSecretStageWithContext(cancelled, testRegion, "token", "", awssecretsmanager.StageCurrent)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFakeTagsDescriptionAndKMSKey(t *testing.T) {
+	mgr := newFakeSecretManager(t, awssecretsmanager.WithKMSKeyID("alias/secrets"))
+	metadata := mgr.(secretstore.MetadataInterface)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "api", &secretstore.SecretValue{
+		Value:       "v1",
+		Labels:      map[string]string{"team": "platform", "env": "dev"},
+		Annotations: map[string]string{awssecretsmanager.DescriptionAnnotation: "api token"},
+	}))
+	m, err := metadata.GetSecretMetadata(testRegion, "api")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "platform", "env": "dev"}, m.Labels)
+	assert.Equal(t, map[string]string{awssecretsmanager.DescriptionAnnotation: "api token"}, m.Annotations)
+	assert.NotEmpty(t, m.Version)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "api", &secretstore.SecretValue{
+		Value:       "v2",
+		Labels:      map[string]string{"team": "security"},
+		Annotations: map[string]string{awssecretsmanager.DescriptionAnnotation: "api token", "owner": "alice"},
+	}))
+	m, err = metadata.GetSecretMetadata(testRegion, "api")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "security", "env": "dev"}, m.Labels)
+	assert.Equal(t, map[string]string{awssecretsmanager.DescriptionAnnotation: "api token", "owner": "alice"}, m.Annotations)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "api", &secretstore.SecretValue{
+		Value:             "v3",
+		Annotations:       map[string]string{"owner": "bob"},
+		RemoveLabels:      []string{"env"},
+		RemoveAnnotations: []string{awssecretsmanager.DescriptionAnnotation},
+	}))
+	m, err = metadata.GetSecretMetadata(testRegion, "api")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "security"}, m.Labels)
+	assert.Equal(t, map[string]string{"owner": "bob"}, m.Annotations)
+}
+
+func TestFakeVersionStages(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+	stages := mgr.(awssecretsmanager.StageInterface)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"username": "admin", "password": "first"},
+	}))
+	versionID, err := stages.SetPendingSecret(testRegion, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "second"},
+	})
+	require.NoError(t, err)
+
+	current, err := mgr.GetSecret(testRegion, "db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "first", current)
+	pending, err := stages.GetSecretStage(testRegion, "db", "password", awssecretsmanager.StagePending)
+	require.NoError(t, err)
+	assert.Equal(t, "second", pending)
+
+	require.NoError(t, stages.PromoteSecretVersion(testRegion, "db", versionID))
+	current, err = mgr.GetSecret(testRegion, "db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "second", current)
+	username, err := mgr.GetSecret(testRegion, "db", "username")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+	previous, err := stages.GetSecretStage(testRegion, "db", "password", awssecretsmanager.StagePrevious)
+	require.NoError(t, err)
+	assert.Equal(t, "first", previous)
+	_, err = stages.GetSecretStage(testRegion, "db", "password", awssecretsmanager.StagePending)
+	assert.True(t, secretstore.IsNotFound(err))
+}
+
+func TestFakeReplicaRegions(t *testing.T) {
+	server := fakeaws.NewSecretsManagerServer()
+	t.Cleanup(server.Close)
+	sess, err := fakeaws.NewSession()
+	require.NoError(t, err)
+	newManager := func(regions ...awssecretsmanager.ReplicaRegion) secretstore.Interface {
+		return awssecretsmanager.NewAwsSecretManager(sess, awssecretsmanager.WithEndpoint(server.URL),
+			awssecretsmanager.WithReplicaRegions(regions...))
+	}
+
+	mgr := newManager(awssecretsmanager.ReplicaRegion{Region: testRegion}, awssecretsmanager.ReplicaRegion{Region: "us-east-1"})
+	require.NoError(t, mgr.SetSecret(testRegion, "shared", &secretstore.SecretValue{Value: "v1"}))
+	statuses, err := mgr.(awssecretsmanager.ReplicationInterface).GetReplicationStatus(testRegion, "shared")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "us-east-1", statuses[0].Region)
+
+	mgr = newManager(awssecretsmanager.ReplicaRegion{Region: "ap-southeast-2", KMSKeyID: "alias/replica"})
+	require.NoError(t, mgr.SetSecret(testRegion, "shared", &secretstore.SecretValue{Value: "v2"}))
+	statuses, err = mgr.(awssecretsmanager.ReplicationInterface).GetReplicationStatus(testRegion, "shared")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "ap-southeast-2", statuses[0].Region)
+	assert.Equal(t, "alias/replica", statuses[0].KMSKeyID)
+}
+
+func TestFakeGetSecrets(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+	require.NoError(t, mgr.SetSecret(testRegion, "one", &secretstore.SecretValue{Value: "1"}))
+	require.NoError(t, mgr.SetSecret(testRegion, "two", &secretstore.SecretValue{PropertyValues: map[string]string{"key": "2"}}))
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: testRegion, SecretName: "one"},
+		{Location: testRegion, SecretName: "two", SecretKey: "key"},
+		{Location: testRegion, SecretName: "missing"},
+	}, 2)
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "1", results[0].Value)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "2", results[1].Value)
+	assert.True(t, secretstore.IsNotFound(results[2].Err))
+}
+
+func TestFakeWrapsSimpleSecret(t *testing.T) {
+	mgr := newFakeSecretManager(t, awssecretsmanager.WithConversionPolicy(awssecretsmanager.ConversionWrap))
+
+	require.NoError(t, mgr.SetSecret(testRegion, "token", &secretstore.SecretValue{Value: "legacy"}))
+	require.NoError(t, mgr.SetSecret(testRegion, "token", &secretstore.SecretValue{PropertyValues: map[string]string{"user": "admin"}}))
+
+	value, err := mgr.GetSecret(testRegion, "token", awssecretsmanager.DefaultWrapKey)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", value)
+	user, err := mgr.GetSecret(testRegion, "token", "user")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", user)
+}
+
+func TestFakeOverwriteReplacesProperties(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "admin"}}))
+	require.NoError(t, mgr.SetSecret(testRegion, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "s3cr3t"},
+		Overwrite:      true,
+	}))
+	value, err := mgr.GetSecret(testRegion, "db", "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"s3cr3t"}`, value)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "plain", &secretstore.SecretValue{Value: "legacy"}))
+	err = mgr.SetSecret(testRegion, "plain", &secretstore.SecretValue{PropertyValues: map[string]string{"user": "admin"}})
+	assert.Error(t, err)
+}
+
+func TestFakeCreateSecret(t *testing.T) {
+	mgr := newFakeSecretManager(t)
+	creator, ok := mgr.(secretstore.CreateInterface)
+	require.True(t, ok)
+
+	require.NoError(t, creator.CreateSecret(testRegion, "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "admin"}}))
+	err := creator.CreateSecret(testRegion, "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "root"}})
+	assert.True(t, secretstore.IsConflict(err))
+
+	password, created, err := generator.SetIfAbsent(mgr, testRegion, "db", "password", func() (string, error) {
+		return "generated", nil
+	})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "generated", password)
+	username, err := mgr.GetSecret(testRegion, "db", "username")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+}
@@ -0,0 +1,183 @@
+package awssecretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// DescriptionAnnotation is the annotation written as the plain text description of a secret. Any other annotations
+// are written to the description as a JSON object.
+const DescriptionAnnotation = "description"
+
+// WithKMSKeyID sets the KMS key, by ID, ARN or alias, used to encrypt secrets instead of the AWS managed key
+func WithKMSKeyID(keyID string) Option {
+	return func(a *awsSecretsManager) {
+		a.kmsKeyID = keyID
+	}
+}
+
+// GetSecretMetadata returns the tags of a secret as labels, its description as annotations and its AWSCURRENT version
+func (a awsSecretsManager) GetSecretMetadata(location, secretName string) (*secretstore.SecretMetadata, error) {
+	return a.GetSecretMetadataWithContext(context.TODO(), location, secretName)
+}
+
+// GetSecretMetadataWithContext returns the metadata of a secret like GetSecretMetadata with the context of the request
+func (a awsSecretsManager) GetSecretMetadataWithContext(ctx context.Context, location, secretName string) (*secretstore.SecretMetadata, error) {
+	output, err := a.client(location).DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(secretName)})
+	if err != nil {
+		return nil, errors.Wrapf(notFoundError(err, location, secretName), "error describing secret %s in aws secret manager", secretName)
+	}
+	return &secretstore.SecretMetadata{
+		Labels:      tagsToLabels(output.Tags),
+		Annotations: descriptionToAnnotations(aws.ToString(output.Description)),
+		Version:     currentVersionID(output),
+		CreateTime:  aws.ToTime(output.CreatedDate),
+		UpdateTime:  aws.ToTime(output.LastChangedDate),
+	}, nil
+}
+
+// updateSecretMetadata merges the labels of the secret value into the tags of an existing secret, and its annotations
+// into the description, removing only the labels and annotations the secret value asks to remove. The secret is also
+// moved to the configured KMS key and replica regions.
+func (a awsSecretsManager) updateSecretMetadata(ctx context.Context, location, secretName string, secretValue *secretstore.SecretValue) error {
+	svc := a.client(location)
+	output, err := svc.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(secretName)})
+	if err != nil {
+		return errors.Wrapf(err, "error describing secret %s", secretName)
+	}
+	err = a.updateReplicas(ctx, svc, location, output)
+	if err != nil {
+		return err
+	}
+
+	changed, removed := mergeLabels(tagsToLabels(output.Tags), secretValue.Labels, secretValue.RemoveLabels)
+	if len(removed) > 0 {
+		_, err = svc.UntagResource(ctx, &secretsmanager.UntagResourceInput{SecretId: output.ARN, TagKeys: removed})
+		if err != nil {
+			return errors.Wrapf(err, "error removing tags from secret %s", secretName)
+		}
+	}
+	if len(changed) > 0 {
+		_, err = svc.TagResource(ctx, &secretsmanager.TagResourceInput{SecretId: output.ARN, Tags: labelsToTags(changed)})
+		if err != nil {
+			return errors.Wrapf(err, "error tagging secret %s", secretName)
+		}
+	}
+
+	input := &secretsmanager.UpdateSecretInput{SecretId: output.ARN}
+	update := false
+	if len(secretValue.Annotations) > 0 || len(secretValue.RemoveAnnotations) > 0 {
+		annotations := descriptionToAnnotations(aws.ToString(output.Description))
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		for _, k := range secretValue.RemoveAnnotations {
+			delete(annotations, k)
+		}
+		for k, v := range secretValue.Annotations {
+			annotations[k] = v
+		}
+		description := annotationsToDescription(annotations)
+		if description != aws.ToString(output.Description) {
+			input.Description = aws.String(description)
+			update = true
+		}
+	}
+	if a.kmsKeyID != "" && a.kmsKeyID != aws.ToString(output.KmsKeyId) {
+		input.KmsKeyId = aws.String(a.kmsKeyID)
+		update = true
+	}
+	if !update {
+		return nil
+	}
+	_, err = svc.UpdateSecret(ctx, input)
+	if err != nil {
+		return errors.Wrapf(err, "error updating description and KMS key of secret %s", secretName)
+	}
+	return nil
+}
+
+// mergeLabels returns the labels that are new or differ from the existing labels, and the keys to remove that exist
+// and aren't set again, ordered
+func mergeLabels(existing, labels map[string]string, remove []string) (map[string]string, []string) {
+	changed := map[string]string{}
+	for k, v := range labels {
+		if current, ok := existing[k]; !ok || current != v {
+			changed[k] = v
+		}
+	}
+	var removed []string
+	for _, k := range remove {
+		if _, ok := existing[k]; !ok {
+			continue
+		}
+		if _, ok := labels[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// labelsToTags converts labels to tags ordered by key
+func labelsToTags(labels map[string]string) []types.Tag {
+	if len(labels) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]types.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(labels[k])})
+	}
+	return tags
+}
+
+func tagsToLabels(tags []types.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, t := range tags {
+		labels[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return labels
+}
+
+// annotationsToDescription writes a lone DescriptionAnnotation as plain text and any other annotations as JSON
+func annotationsToDescription(annotations map[string]string) string {
+	if len(annotations) == 0 {
+		return ""
+	}
+	if description, ok := annotations[DescriptionAnnotation]; ok && len(annotations) == 1 {
+		return description
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// descriptionToAnnotations reverses annotationsToDescription, treating a description that isn't a JSON object as
+// plain text
+func descriptionToAnnotations(description string) map[string]string {
+	if description == "" {
+		return nil
+	}
+	annotations := map[string]string{}
+	if err := json.Unmarshal([]byte(description), &annotations); err == nil {
+		return annotations
+	}
+	return map[string]string{DescriptionAnnotation: description}
+}
@@ -0,0 +1,32 @@
+package awssecretsmanager
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationsToDescription(t *testing.T) {
+	assert.Equal(t, "", annotationsToDescription(nil))
+	assert.Equal(t, "database credentials", annotationsToDescription(map[string]string{DescriptionAnnotation: "database credentials"}))
+
+	annotations := map[string]string{DescriptionAnnotation: "database credentials", "owner": "platform"}
+	description := annotationsToDescription(annotations)
+	assert.Equal(t, `{"description":"database credentials","owner":"platform"}`, description)
+	assert.Equal(t, annotations, descriptionToAnnotations(description))
+
+	assert.Nil(t, descriptionToAnnotations(""))
+	assert.Equal(t, map[string]string{DescriptionAnnotation: "created by hand"}, descriptionToAnnotations("created by hand"))
+}
+
+func TestLabelsToTags(t *testing.T) {
+	assert.Nil(t, labelsToTags(nil))
+	tags := labelsToTags(map[string]string{"team": "platform", "env": "prod"})
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("team"), Value: aws.String("platform")},
+	}, tags)
+	assert.Equal(t, map[string]string{"team": "platform", "env": "prod"}, tagsToLabels(tags))
+}
@@ -0,0 +1,132 @@
+package awssecretsmanager
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/pkg/errors"
+)
+
+// ReplicaRegion is a region that secrets are replicated to
+type ReplicaRegion struct {
+	Region string
+	// KMSKeyID is the KMS key in the replica region used to encrypt the replica, the AWS managed key if empty
+	KMSKeyID string
+}
+
+// ReplicaStatus is the state of the replica of a secret in a region
+type ReplicaStatus struct {
+	Region   string
+	KMSKeyID string
+	// Status is InSync, Failed or InProgress
+	Status           string
+	StatusMessage    string
+	LastAccessedDate time.Time
+}
+
+// ReplicationInterface reads the replication status of AWS secrets. It is implemented by the secret manager returned
+// by NewAwsSecretManager.
+type ReplicationInterface interface {
+	GetReplicationStatus(location, secretName string) ([]ReplicaStatus, error)
+	GetReplicationStatusWithContext(ctx context.Context, location, secretName string) ([]ReplicaStatus, error)
+}
+
+// WithReplicaRegions replicates secrets written to any location to the other regions. Secrets are created with the
+// replicas and existing secrets are replicated to missing regions and removed from regions no longer listed. The
+// replicas of existing secrets are left alone if no regions are given.
+func WithReplicaRegions(regions ...ReplicaRegion) Option {
+	return func(a *awsSecretsManager) {
+		a.replicaRegions = append(a.replicaRegions, regions...)
+	}
+}
+
+func (a awsSecretsManager) GetReplicationStatus(location, secretName string) ([]ReplicaStatus, error) {
+	return a.GetReplicationStatusWithContext(context.TODO(), location, secretName)
+}
+
+func (a awsSecretsManager) GetReplicationStatusWithContext(ctx context.Context, location, secretName string) ([]ReplicaStatus, error) {
+	output, err := a.client(location).DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(secretName)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error describing secret %s in aws secret manager", secretName)
+	}
+	statuses := make([]ReplicaStatus, 0, len(output.ReplicationStatus))
+	for _, s := range output.ReplicationStatus {
+		statuses = append(statuses, ReplicaStatus{
+			Region:           aws.ToString(s.Region),
+			KMSKeyID:         aws.ToString(s.KmsKeyId),
+			Status:           string(s.Status),
+			StatusMessage:    aws.ToString(s.StatusMessage),
+			LastAccessedDate: aws.ToTime(s.LastAccessedDate),
+		})
+	}
+	return statuses, nil
+}
+
+// addRegions returns the replica regions of a secret whose primary region is location
+func (a awsSecretsManager) addRegions(location string) []types.ReplicaRegionType {
+	var regions []types.ReplicaRegionType
+	for _, r := range a.replicaRegions {
+		if r.Region == location {
+			continue
+		}
+		region := types.ReplicaRegionType{Region: aws.String(r.Region)}
+		if r.KMSKeyID != "" {
+			region.KmsKeyId = aws.String(r.KMSKeyID)
+		}
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+// updateReplicas replicates an existing secret to the configured regions it is missing from, and removes it from
+// regions that are no longer configured
+func (a awsSecretsManager) updateReplicas(ctx context.Context, svc *secretsmanager.Client, location string, secret *secretsmanager.DescribeSecretOutput) error {
+	if len(a.replicaRegions) == 0 {
+		return nil
+	}
+	existing := map[string]bool{}
+	for _, s := range secret.ReplicationStatus {
+		existing[aws.ToString(s.Region)] = true
+	}
+
+	expected := map[string]bool{}
+	var added []types.ReplicaRegionType
+	for _, r := range a.addRegions(location) {
+		region := aws.ToString(r.Region)
+		expected[region] = true
+		if !existing[region] {
+			added = append(added, r)
+		}
+	}
+	var removed []string
+	for region := range existing {
+		if !expected[region] {
+			removed = append(removed, region)
+		}
+	}
+	sort.Strings(removed)
+
+	if len(added) > 0 {
+		_, err := svc.ReplicateSecretToRegions(ctx, &secretsmanager.ReplicateSecretToRegionsInput{
+			SecretId:          secret.ARN,
+			AddReplicaRegions: added,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error replicating secret %s to new regions", aws.ToString(secret.Name))
+		}
+	}
+	if len(removed) > 0 {
+		_, err := svc.RemoveRegionsFromReplication(ctx, &secretsmanager.RemoveRegionsFromReplicationInput{
+			SecretId:             secret.ARN,
+			RemoveReplicaRegions: removed,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error removing replicas of secret %s from regions %v", aws.ToString(secret.Name), removed)
+		}
+	}
+	return nil
+}
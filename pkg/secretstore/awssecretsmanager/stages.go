@@ -0,0 +1,148 @@
+package awssecretsmanager
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Staging labels Secrets Manager gives the versions of a secret during rotation
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+	StagePending  = "AWSPENDING"
+)
+
+// StageInterface supports two-phase rotation of AWS secrets: a new value is written as AWSPENDING, tested, then
+// promoted to AWSCURRENT, at which point the replaced version becomes AWSPREVIOUS. It is implemented by the secret
+// manager returned by NewAwsSecretManager.
+type StageInterface interface {
+	// GetSecretStage returns the value, or a property of the value, of the version of a secret with a staging label
+	GetSecretStage(location, secretName, secretKey, stage string) (string, error)
+	// SetPendingSecret writes a version labelled AWSPENDING and returns its version ID. Property values are merged into
+	// those of the AWSCURRENT version.
+	SetPendingSecret(location, secretName string, secretValue *secretstore.SecretValue) (string, error)
+	// PromoteSecretVersion moves AWSCURRENT, and removes AWSPENDING, to the version
+	PromoteSecretVersion(location, secretName, versionID string) error
+
+	GetSecretStageWithContext(ctx context.Context, location, secretName, secretKey, stage string) (string, error)
+	SetPendingSecretWithContext(ctx context.Context, location, secretName string, secretValue *secretstore.SecretValue) (string, error)
+	PromoteSecretVersionWithContext(ctx context.Context, location, secretName, versionID string) error
+}
+
+func (a awsSecretsManager) GetSecretStage(location, secretName, secretKey, stage string) (string, error) {
+	return a.GetSecretStageWithContext(context.TODO(), location, secretName, secretKey, stage)
+}
+
+func (a awsSecretsManager) GetSecretStageWithContext(ctx context.Context, location, secretName, secretKey, stage string) (string, error) {
+	secret, err := a.client(location).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(secretName),
+		VersionStage: aws.String(stage),
+	})
+	if err != nil {
+		return "", errors.Wrapf(notFoundError(err, location, secretName), "error retrieving %s version of secret %s from aws secret manager", stage, secretName)
+	}
+	if secretKey == "" {
+		return secretString(secret.SecretString, secret.SecretBinary), nil
+	}
+	value, err := getSecretProperty(secret, secretKey)
+	if err != nil {
+		return "", errors.Wrapf(err, "error retrieving secret property from %s version of secret %s", stage, secretName)
+	}
+	return value, nil
+}
+
+func (a awsSecretsManager) SetPendingSecret(location, secretName string, secretValue *secretstore.SecretValue) (string, error) {
+	return a.SetPendingSecretWithContext(context.TODO(), location, secretName, secretValue)
+}
+
+func (a awsSecretsManager) SetPendingSecretWithContext(ctx context.Context, location, secretName string, secretValue *secretstore.SecretValue) (string, error) {
+	svc := a.client(location)
+
+	current, err := svc.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(secretName),
+		VersionStage: aws.String(StageCurrent),
+	})
+	switch err = notFoundError(err, location, secretName); {
+	case secretstore.IsNotFound(err):
+		// a secret created without a value has no versions, so the pending version is its first
+		_, err = svc.CreateSecret(ctx, a.createSecretInput(location, secretName, secretValue))
+		if err != nil && !isAlreadyExists(err) {
+			return "", errors.Wrapf(err, "error creating new secret %s for aws secret manager", secretName)
+		}
+	case err != nil:
+		return "", errors.Wrapf(err, "error retrieving current version of secret %s from aws secret manager", secretName)
+	default:
+		err = a.updateSecretMetadata(ctx, location, secretName, secretValue)
+		if err != nil {
+			return "", errors.Wrapf(err, "error updating existing secret %s for aws secret manager", secretName)
+		}
+	}
+
+	input := &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(secretName),
+		VersionStages: []string{StagePending},
+	}
+	if secretValue.BinaryValue != nil {
+		input.SecretBinary = secretValue.BinaryValue
+	} else {
+		value, err := a.mergeSecretString(secretName, current, secretValue)
+		if err != nil {
+			return "", err
+		}
+		input.SecretString = aws.String(value)
+	}
+	output, err := svc.PutSecretValue(ctx, input)
+	if err != nil {
+		return "", errors.Wrapf(err, "error writing pending version of secret %s to aws secret manager", secretName)
+	}
+	return aws.ToString(output.VersionId), nil
+}
+
+func (a awsSecretsManager) PromoteSecretVersion(location, secretName, versionID string) error {
+	return a.PromoteSecretVersionWithContext(context.TODO(), location, secretName, versionID)
+}
+
+func (a awsSecretsManager) PromoteSecretVersionWithContext(ctx context.Context, location, secretName, versionID string) error {
+	svc := a.client(location)
+	output, err := svc.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(secretName)})
+	if err != nil {
+		return errors.Wrapf(err, "error describing secret %s in aws secret manager", secretName)
+	}
+	if _, ok := output.VersionIdsToStages[versionID]; !ok {
+		return errors.Errorf("secret %s in aws secret manager has no version %s", secretName, versionID)
+	}
+
+	if current := currentVersionID(output); current != versionID {
+		input := &secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:        aws.String(secretName),
+			VersionStage:    aws.String(StageCurrent),
+			MoveToVersionId: aws.String(versionID),
+		}
+		if current != "" {
+			input.RemoveFromVersionId = aws.String(current)
+		}
+		_, err = svc.UpdateSecretVersionStage(ctx, input)
+		if err != nil {
+			return errors.Wrapf(err, "error promoting version %s of secret %s to %s", versionID, secretName, StageCurrent)
+		}
+	}
+
+	for _, stage := range output.VersionIdsToStages[versionID] {
+		if stage != StagePending {
+			continue
+		}
+		_, err = svc.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:            aws.String(secretName),
+			VersionStage:        aws.String(StagePending),
+			RemoveFromVersionId: aws.String(versionID),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error removing %s from version %s of secret %s", StagePending, versionID, secretName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package awssecretsmanager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Watch polls the version of the secret labelled AWSCURRENT
+func (a awsSecretsManager) Watch(location, secretName string) (secretstore.Watcher, error) {
+	return secretstore.NewPollingWatcherWithContext(location, secretName, secretstore.DefaultWatchPollInterval, func(ctx context.Context) (string, error) {
+		return a.currentVersion(ctx, location, secretName)
+	}), nil
+}
+
+func (a awsSecretsManager) currentVersion(ctx context.Context, location, secretName string) (string, error) {
+	output, err := a.client(location).DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(secretName)})
+	if err != nil {
+		return "", errors.Wrapf(notFoundError(err, location, secretName), "error describing secret %s in aws secret manager", secretName)
+	}
+	versionID := currentVersionID(output)
+	if versionID == "" {
+		return "", fmt.Errorf("no AWSCURRENT version found for secret %s in aws secret manager", secretName)
+	}
+	return versionID, nil
+}
+
+// currentVersionID returns the ID of the version labelled AWSCURRENT, or an empty string if there is none
+func currentVersionID(output *secretsmanager.DescribeSecretOutput) string {
+	for versionID, stages := range output.VersionIdsToStages {
+		for _, stage := range stages {
+			if stage == StageCurrent {
+				return versionID
+			}
+		}
+	}
+	return ""
+}
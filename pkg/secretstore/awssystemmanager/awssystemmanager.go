@@ -1,50 +1,182 @@
 package awssystemmanager
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/awsiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
 	"github.com/pkg/errors"
 )
 
-func NewAwsSystemManager(session *session.Session) secretstore.Interface {
-	return awsSystemManager{session}
+// NewAwsSystemManager returns a secret manager using the region, credentials and HTTP client of a v1 SDK session.
+//
+// Deprecated: NewAwsSystemManager is kept for callers of the v1 SDK. Use NewAwsSystemManagerFromConfig.
+func NewAwsSystemManager(session *session.Session, opts ...Option) secretstore.Interface {
+	return NewAwsSystemManagerFromConfig(awsiam.ConfigFromSession(session), opts...)
+}
+
+// NewAwsSystemManagerFromConfig returns a secret manager using an aws-sdk-go-v2 config. The region of the config is
+// replaced by the location of each call.
+func NewAwsSystemManagerFromConfig(config aws.Config, opts ...Option) secretstore.Interface {
+	a := &awsSystemManager{config: config, clients: &clientCache{clients: map[string]*ssm.Client{}}}
+	for _, o := range opts {
+		o(a)
+	}
+	return *a
 }
 
 type awsSystemManager struct {
-	session *session.Session
+	config        aws.Config
+	clients       *clientCache
+	endpoint      string
+	httpClient    *http.Client
+	parameterType string
+	kmsKeyID      string
+	tier          string
+
+	expirationNotification time.Duration
+}
+
+// clientCache holds a client per region, as clients are safe for concurrent use
+type clientCache struct {
+	lock    sync.Mutex
+	clients map[string]*ssm.Client
+}
+
+// Option configures an AWS Systems Manager Parameter Store secret manager
+type Option func(*awsSystemManager)
+
+// WithEndpoint overrides the Systems Manager endpoint of every region, e.g. to use a VPC endpoint or a local fake
+func WithEndpoint(endpoint string) Option {
+	return func(a *awsSystemManager) {
+		a.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to call Systems Manager
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *awsSystemManager) {
+		a.httpClient = client
+	}
+}
+
+// client returns the Systems Manager client of the region, creating it on first use
+func (a awsSystemManager) client(location string) *ssm.Client {
+	a.clients.lock.Lock()
+	defer a.clients.lock.Unlock()
+	if c, ok := a.clients.clients[location]; ok {
+		return c
+	}
+	c := ssm.NewFromConfig(a.config, func(o *ssm.Options) {
+		o.Region = location
+		if a.endpoint != "" {
+			o.BaseEndpoint = aws.String(a.endpoint)
+		}
+		if a.httpClient != nil {
+			o.HTTPClient = a.httpClient
+		}
+	})
+	a.clients.clients[location] = c
+	return c
+}
+
+func (a awsSystemManager) GetSecret(location, secretName, secretKey string) (string, error) {
+	return a.GetSecretWithContext(context.TODO(), location, secretName, secretKey)
 }
 
-func (a awsSystemManager) GetSecret(location, secretName, _ string) (string, error) {
+// GetSecretWithContext reads a parameter, a property of it or a path with the context of the requests
+func (a awsSystemManager) GetSecretWithContext(ctx context.Context, location, secretName, secretKey string) (string, error) {
+	if IsPath(secretName) {
+		return a.getPathSecret(ctx, location, secretName, secretKey)
+	}
 	input := &ssm.GetParameterInput{
-		Name: aws.String(secretName),
+		Name:           aws.String(secretName),
+		WithDecryption: aws.Bool(true),
+	}
+	result, err := a.client(location).GetParameter(ctx, input)
+	if err != nil {
+		return "", errors.Wrap(notFoundError(err, location, secretName), "error retrieving secret from aws parameter store")
+	}
+	value := aws.ToString(result.Parameter.Value)
+	if secretKey == "" {
+		return value, nil
+	}
+	return getSecretProperty(value, secretKey)
+}
+
+// getSecretProperty reads a property of a parameter holding a JSON object
+func getSecretProperty(value, propertyName string) (string, error) {
+	m, err := getSecretPropertyMap(value)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading property %s from parameter JSON object", propertyName)
 	}
-	mgr := ssm.New(a.session, aws.NewConfig().WithRegion(location))
-	mgr.Config.Region = &location
-	result, err := mgr.GetParameter(input)
+	return m[propertyName], nil
+}
+
+// getSecretPropertyMap parses the properties of a parameter, returning values that aren't strings as JSON text
+func getSecretPropertyMap(value string) (map[string]string, error) {
+	var properties map[string]json.RawMessage
+	err := json.Unmarshal([]byte(value), &properties)
 	if err != nil {
-		return "", errors.Wrap(err, "error retrieving secret from aws parameter store")
+		return nil, errors.Wrap(err, "error unmarshalling AWS parameter store parameter value in to a JSON object")
 	}
-	return result.String(), nil
+	m := make(map[string]string, len(properties))
+	for k, raw := range properties {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		m[k] = s
+	}
+	return m, nil
 }
 
+// SetSecret writes a parameter, or the parameters of a path. An existing parameter is only replaced when Overwrite is
+// set, whereas a path is written property by property like the merging secret managers: the parameters of properties
+// that already exist are always replaced, and Overwrite also deletes the parameters that aren't properties.
 func (a awsSystemManager) SetSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
-	input := &ssm.PutParameterInput{
-		Name:  &secretName,
-		Value: &secretValue.Value,
+	return a.SetSecretWithContext(context.TODO(), location, secretName, secretValue)
+}
+
+// CreateSecret writes a parameter, or the parameters of a path, returning a secretstore.ConflictError if any of them
+// already exists
+func (a awsSystemManager) CreateSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
+	create := *secretValue
+	create.Overwrite = false
+	if IsPath(secretName) {
+		return a.setPathSecret(context.TODO(), location, secretName, &create, true)
 	}
-	mgr := ssm.New(a.session, aws.NewConfig().WithRegion(location))
-	mgr.Config.Region = &location
+	return a.SetSecretWithContext(context.TODO(), location, secretName, &create)
+}
 
-	_, err := mgr.PutParameter(input)
+// SetSecretWithContext writes a parameter, or the parameters of a path, with the context of the requests
+func (a awsSystemManager) SetSecretWithContext(ctx context.Context, location, secretName string, secretValue *secretstore.SecretValue) error {
+	if IsPath(secretName) {
+		return a.setPathSecret(ctx, location, secretName, secretValue, false)
+	}
+	input, err := a.putParameterInput(secretName, secretValue)
+	if err != nil {
+		return err
+	}
+	mgr := a.client(location)
+	_, err = mgr.PutParameter(ctx, input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == ssm.ErrCodeParameterAlreadyExists {
-				return errors.Wrap(err, "Secret Already Exists")
-			}
-			return errors.Wrap(err, "error setting secret for aws parameter store")
+		if isAlreadyExists(err) {
+			return &secretstore.ConflictError{Location: location, SecretName: secretName, Err: err}
+		}
+		return errors.Wrap(err, "error setting secret for aws parameter store")
+	}
+	if secretValue.Overwrite && (len(secretValue.Labels) > 0 || len(secretValue.RemoveLabels) > 0) {
+		err = updateTags(ctx, mgr, secretName, secretValue.Labels, secretValue.RemoveLabels)
+		if err != nil {
+			return errors.Wrap(err, "error updating tags of secret in aws parameter store")
 		}
 	}
 	return nil
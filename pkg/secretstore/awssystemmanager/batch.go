@@ -0,0 +1,132 @@
+package awssystemmanager
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// maxGetParameters is the maximum number of names accepted by a single GetParameters call
+const maxGetParameters = 10
+
+// GetSecrets reads the parameters of each region with GetParameters, 10 parameters per call, reading the properties
+// of JSON-valued parameters for refs with a key. Paths are read one at a time. At most concurrency calls are made at
+// once.
+func (a awsSystemManager) GetSecrets(refs []secretstore.SecretRef, concurrency int) []secretstore.GetResult {
+	return a.GetSecretsWithContext(context.TODO(), refs, concurrency)
+}
+
+// GetSecretsWithContext reads the parameters like GetSecrets with the context of the requests
+func (a awsSystemManager) GetSecretsWithContext(ctx context.Context, refs []secretstore.SecretRef, concurrency int) []secretstore.GetResult {
+	results := make([]secretstore.GetResult, len(refs))
+	for i, ref := range refs {
+		results[i].Ref = ref
+	}
+
+	// each chunk is read by a single call
+	type chunk struct {
+		location string
+		names    []string
+		// path is the index of a ref to a path, which is read by GetSecret, or -1
+		path   int
+		values map[string]string
+		errs   map[string]error
+	}
+	var chunks []*chunk
+	chunkOf := map[string]*chunk{}
+	locations, byLocation := secretstore.GroupRefsByLocation(refs)
+	for _, location := range locations {
+		var c *chunk
+		for _, i := range byLocation[location] {
+			if IsPath(refs[i].SecretName) {
+				// GetParameters can't read paths so they are read one at a time
+				chunks = append(chunks, &chunk{location: location, path: i})
+				continue
+			}
+			key := location + "/" + refs[i].SecretName
+			if _, ok := chunkOf[key]; ok {
+				continue
+			}
+			if c == nil || len(c.names) == maxGetParameters {
+				c = &chunk{location: location, path: -1}
+				chunks = append(chunks, c)
+			}
+			c.names = append(c.names, refs[i].SecretName)
+			chunkOf[key] = c
+		}
+	}
+	secretstore.Parallel(len(chunks), concurrency, func(i int) {
+		c := chunks[i]
+		if c.path >= 0 {
+			ref := refs[c.path]
+			results[c.path].Value, results[c.path].Err = a.GetSecretWithContext(ctx, c.location, ref.SecretName, ref.SecretKey)
+			return
+		}
+		c.values, c.errs = a.getParameters(ctx, c.location, c.names)
+	})
+
+	for i, ref := range refs {
+		c, ok := chunkOf[ref.Location+"/"+ref.SecretName]
+		if !ok {
+			continue
+		}
+		if err, ok := c.errs[ref.SecretName]; ok {
+			results[i].Err = err
+			continue
+		}
+		value, ok := c.values[ref.SecretName]
+		if !ok {
+			results[i].Err = &secretstore.NotFoundError{Location: ref.Location, SecretName: ref.SecretName}
+			continue
+		}
+		if ref.SecretKey != "" {
+			value, err := getSecretProperty(value, ref.SecretKey)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Value = value
+			continue
+		}
+		results[i].Value = value
+	}
+	return results
+}
+
+// getParameters reads up to maxGetParameters parameters and returns the values found and the errors for those that
+// could not be read, both keyed by the requested name
+func (a awsSystemManager) getParameters(ctx context.Context, location string, names []string) (map[string]string, map[string]error) {
+	values := map[string]string{}
+	errs := map[string]error{}
+	output, err := a.client(location).GetParameters(ctx, &ssm.GetParametersInput{
+		Names:          names,
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		for _, name := range names {
+			errs[name] = errors.Wrap(err, "error retrieving secrets from aws parameter store")
+		}
+		return values, errs
+	}
+	for _, p := range output.Parameters {
+		// parameters read with a name:version or name:label selector are returned with the selector separately
+		values[aws.ToString(p.Name)+aws.ToString(p.Selector)] = aws.ToString(p.Value)
+	}
+	for _, name := range output.InvalidParameters {
+		errs[name] = &secretstore.NotFoundError{Location: location, SecretName: name}
+	}
+	return values, errs
+}
+
+// SetSecrets writes the parameters from a pool of workers as Parameter Store has no batch write
+func (a awsSystemManager) SetSecrets(writes []secretstore.SecretWrite, concurrency int) []secretstore.SetResult {
+	return a.SetSecretsWithContext(context.TODO(), writes, concurrency)
+}
+
+// SetSecretsWithContext writes the parameters like SetSecrets with the context of the requests
+func (a awsSystemManager) SetSecretsWithContext(ctx context.Context, writes []secretstore.SecretWrite, concurrency int) []secretstore.SetResult {
+	return secretstore.ParallelSetSecretsWithContext(ctx, a, writes, concurrency)
+}
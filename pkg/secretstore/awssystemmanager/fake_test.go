@@ -0,0 +1,384 @@
+package awssystemmanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/awssystemmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fakeaws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegion = "eu-west-1"
+
+func newFakeSystemManager(t *testing.T, opts ...awssystemmanager.Option) (*fakeaws.SSMServer, secretstore.Interface) {
+	server := fakeaws.NewSSMServer()
+	t.Cleanup(server.Close)
+	opts = append([]awssystemmanager.Option{
+		awssystemmanager.WithEndpoint(server.URL),
+		awssystemmanager.WithHTTPClient(server.Client()),
+	}, opts...)
+	return server, awssystemmanager.NewAwsSystemManagerFromConfig(fakeaws.NewConfig(), opts...)
+}
+
+// newSSMClient returns a client of the fake for inspecting parameters
+func newSSMClient(server *fakeaws.SSMServer) *ssm.Client {
+	return ssm.NewFromConfig(fakeaws.NewConfig(), func(o *ssm.Options) {
+		o.Region = testRegion
+		o.BaseEndpoint = aws.String(server.URL)
+	})
+}
+
+func TestFakeGetSecrets(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	server.PutParameter(testRegion, "/app/url", "String", "https://example.com")
+	server.PutParameter(testRegion, "/app/token", "SecureString", "s3cr3t")
+	server.PutParameter("us-west-2", "/app/url", "String", "https://example.org")
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: testRegion, SecretName: "/app/url"},
+		{Location: testRegion, SecretName: "/app/token"},
+		{Location: "us-west-2", SecretName: "/app/url"},
+		{Location: testRegion, SecretName: "/app/missing"},
+	}, 2)
+	require.Len(t, results, 4)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "https://example.com", results[0].Value)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "s3cr3t", results[1].Value)
+	require.NoError(t, results[2].Err)
+	assert.Equal(t, "https://example.org", results[2].Value)
+	assert.True(t, secretstore.IsNotFound(results[3].Err))
+}
+
+func TestFakeSetExistingSecret(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	server.PutParameter(testRegion, "/app/url", "String", "https://example.com")
+
+	err := mgr.SetSecret(testRegion, "/app/url", &secretstore.SecretValue{Value: "https://example.org"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ParameterAlreadyExists")
+}
+
+func TestFakeSetSecureStringParameter(t *testing.T) {
+	server, mgr := newFakeSystemManager(t,
+		awssystemmanager.WithKMSKeyID("alias/params"),
+		awssystemmanager.WithTier(string(types.ParameterTierAdvanced)))
+	client := newSSMClient(server)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{
+		Value:       "first",
+		Labels:      map[string]string{"team": "platform", "env": "dev"},
+		Annotations: map[string]string{awssystemmanager.DescriptionAnnotation: "api token"},
+	}))
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{
+		Value:     "second",
+		Labels:    map[string]string{"team": "security"},
+		Overwrite: true,
+	}))
+
+	history, err := client.GetParameterHistory(context.TODO(), &ssm.GetParameterHistoryInput{Name: aws.String("/app/token"), WithDecryption: aws.Bool(true)})
+	require.NoError(t, err)
+	require.Len(t, history.Parameters, 2)
+	latest := history.Parameters[1]
+	assert.Equal(t, "second", aws.ToString(latest.Value))
+	assert.Equal(t, types.ParameterTypeSecureString, (latest.Type))
+	assert.Equal(t, "alias/params", aws.ToString(latest.KeyId))
+	assert.Equal(t, types.ParameterTierAdvanced, (latest.Tier))
+	assert.Equal(t, "api token", aws.ToString(latest.Description))
+
+	tags, err := client.ListTagsForResource(context.TODO(), &ssm.ListTagsForResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String("/app/token"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "security", "env": "dev"}, tagMap(tags.TagList))
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{
+		Value:        "third",
+		RemoveLabels: []string{"env"},
+		Overwrite:    true,
+	}))
+	tags, err = client.ListTagsForResource(context.TODO(), &ssm.ListTagsForResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String("/app/token"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "security"}, tagMap(tags.TagList))
+}
+
+func tagMap(tags []types.Tag) map[string]string {
+	m := map[string]string{}
+	for _, t := range tags {
+		m[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return m
+}
+
+func TestFakeSecretWithContext(t *testing.T) {
+	_, mgr := newFakeSystemManager(t)
+	ctxMgr, ok := mgr.(secretstore.ContextInterface)
+	require.True(t, ok)
+
+	ctx := context.Background()
+	require.NoError(t, ctxMgr.SetSecretWithContext(ctx, testRegion, "/app/token", &secretstore.SecretValue{Value: "first"}))
+	value, err := ctxMgr.GetSecretWithContext(ctx, testRegion, "/app/token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = ctxMgr.GetSecretWithContext(cancelled, testRegion, "/app/token", "")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	results := secretstore.GetSecretsWithContext(cancelled, mgr, []secretstore.SecretRef{
+		{Location: testRegion, SecretName: "/app/token"},
+		{Location: testRegion, SecretName: "/app/"},
+	}, 1)
+	assert.ErrorIs(t, results[0].Err, context.Canceled)
+	assert.ErrorIs(t, results[1].Err, context.Canceled)
+	parameters := mgr.(awssystemmanager.ParameterInterface)
+	_, err = parameters.GetSecretPoliciesWithContext(cancelled, testRegion, "/app/token")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFakeSetSecureStringParameterByDefault(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	client := newSSMClient(server)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{Value: "s3cr3t"}))
+	output, err := client.GetParameter(context.TODO(), &ssm.GetParameterInput{Name: aws.String("/app/token"), WithDecryption: aws.Bool(true)})
+	require.NoError(t, err)
+	assert.Equal(t, types.ParameterTypeSecureString, (output.Parameter.Type))
+	assert.Equal(t, "s3cr3t", aws.ToString(output.Parameter.Value))
+}
+
+func TestFakeSetStringParameter(t *testing.T) {
+	server, mgr := newFakeSystemManager(t, awssystemmanager.WithParameterType(string(types.ParameterTypeString)))
+	client := newSSMClient(server)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/url", &secretstore.SecretValue{Value: "https://example.com"}))
+	output, err := client.GetParameter(context.TODO(), &ssm.GetParameterInput{Name: aws.String("/app/url")})
+	require.NoError(t, err)
+	assert.Equal(t, types.ParameterTypeString, (output.Parameter.Type))
+	assert.Equal(t, "https://example.com", aws.ToString(output.Parameter.Value))
+
+	_, mgr = newFakeSystemManager(t,
+		awssystemmanager.WithParameterType(string(types.ParameterTypeString)),
+		awssystemmanager.WithKMSKeyID("alias/params"))
+	err = mgr.SetSecret(testRegion, "/app/url", &secretstore.SecretValue{Value: "https://example.com"})
+	assert.Error(t, err, "a KMS key can't encrypt String parameters")
+}
+
+func TestFakeGetSecret(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	server.PutParameter(testRegion, "/app/token", "SecureString", "s3cr3t")
+	server.PutParameter(testRegion, "/app/db", "SecureString", `{"username":"admin","port":5432}`)
+
+	value, err := mgr.GetSecret(testRegion, "/app/token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value)
+
+	username, err := mgr.GetSecret(testRegion, "/app/db", "username")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+	port, err := mgr.GetSecret(testRegion, "/app/db", "port")
+	require.NoError(t, err)
+	assert.Equal(t, "5432", port)
+
+	_, err = mgr.GetSecret(testRegion, "/app/token", "username")
+	assert.Error(t, err)
+	_, err = mgr.GetSecret(testRegion, "/app/missing", "")
+	assert.True(t, secretstore.IsNotFound(err))
+}
+
+func TestFakeGetSecretsWithKeys(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	server.PutParameter(testRegion, "/app/db", "String", `{"username":"admin","password":"s3cr3t"}`)
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: testRegion, SecretName: "/app/db", SecretKey: "username"},
+		{Location: testRegion, SecretName: "/app/db", SecretKey: "password"},
+	}, 2)
+	require.Len(t, results, 2)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "admin", results[0].Value)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "s3cr3t", results[1].Value)
+}
+
+func TestFakePathSecret(t *testing.T) {
+	server, mgr := newFakeSystemManager(t, awssystemmanager.WithKMSKeyID("alias/params"))
+	server.PutParameter(testRegion, "/app/prod/db-old", "String", "not under the path")
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/prod/db/", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"user": "admin", "password": "first", "replica/host": "db-2"},
+	}))
+	for i := 0; i < 12; i++ {
+		server.PutParameter(testRegion, fmt.Sprintf("/app/prod/db/extra/%02d", i), "String", strconv.Itoa(i))
+	}
+
+	password, err := mgr.GetSecret(testRegion, "/app/prod/db/", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "first", password)
+	host, err := mgr.GetSecret(testRegion, "/app/prod/db/", "replica/host")
+	require.NoError(t, err)
+	assert.Equal(t, "db-2", host)
+
+	value, err := mgr.GetSecret(testRegion, "/app/prod/db/", "")
+	require.NoError(t, err)
+	properties := map[string]string{}
+	require.NoError(t, json.Unmarshal([]byte(value), &properties))
+	assert.Len(t, properties, 15)
+	assert.Equal(t, "admin", properties["user"])
+	assert.Equal(t, "11", properties["extra/11"])
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/prod/db/", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "second", "port": "5432"},
+	}))
+	value, err = mgr.GetSecret(testRegion, "/app/prod/db/", "")
+	require.NoError(t, err)
+	properties = map[string]string{}
+	require.NoError(t, json.Unmarshal([]byte(value), &properties))
+	assert.Len(t, properties, 16, "a write without Overwrite should leave the other parameters alone")
+	assert.Equal(t, "admin", properties["user"])
+	assert.Equal(t, "second", properties["password"])
+	assert.Equal(t, "5432", properties["port"])
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/prod/db/", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"user": "admin", "password": "second"},
+		Overwrite:      true,
+	}))
+	value, err = mgr.GetSecret(testRegion, "/app/prod/db/", "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"user":"admin","password":"second"}`, value)
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: testRegion, SecretName: "/app/prod/db/", SecretKey: "password"},
+		{Location: testRegion, SecretName: "/app/prod/db-old"},
+	}, 2)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "second", results[0].Value)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "not under the path", results[1].Value)
+
+	_, err = mgr.GetSecret(testRegion, "/app/missing/", "")
+	assert.True(t, secretstore.IsNotFound(err))
+}
+
+func TestFakePathSecretReplacesExistingPropertiesWithoutOverwrite(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	client := newSSMClient(server)
+	server.PutParameter(testRegion, "/app/url", "String", "https://example.com")
+	server.PutParameter(testRegion, "/app/prod/api/url", "String", "https://example.com")
+
+	err := mgr.SetSecret(testRegion, "/app/url", &secretstore.SecretValue{Value: "https://example.org"})
+	require.Error(t, err, "a single parameter is only replaced with Overwrite")
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/prod/api/", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"url": "https://example.org", "token": "s3cr3t"},
+		Labels:         map[string]string{"team": "api"},
+	}), "the property of a path is replaced without Overwrite")
+	url, err := mgr.GetSecret(testRegion, "/app/prod/api/", "url")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org", url)
+
+	for _, name := range []string{"/app/prod/api/url", "/app/prod/api/token"} {
+		tags, err := client.ListTagsForResource(context.TODO(), &ssm.ListTagsForResourceInput{
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			ResourceId:   aws.String(name),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "api"}, tagMap(tags.TagList), "tags of %s", name)
+	}
+}
+
+func TestFakeParameterPolicies(t *testing.T) {
+	server, mgr := newFakeSystemManager(t, awssystemmanager.WithExpirationNotification(24*time.Hour))
+	client := newSSMClient(server)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{
+		Value:     "s3cr3t",
+		Lifecycle: &secretstore.SecretLifecycle{TTL: 7 * 24 * time.Hour, RotationPeriod: 24 * time.Hour},
+	}))
+	policies, err := mgr.(awssystemmanager.ParameterInterface).GetSecretPolicies(testRegion, "/app/token")
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+	assert.Equal(t, awssystemmanager.PolicyExpiration, policies[0].Type)
+	assert.Equal(t, awssystemmanager.PolicyExpirationNotification, policies[1].Type)
+	assert.Equal(t, awssystemmanager.PolicyNoChangeNotification, policies[2].Type)
+
+	output, err := client.GetParameterHistory(context.TODO(), &ssm.GetParameterHistoryInput{Name: aws.String("/app/token")})
+	require.NoError(t, err)
+	assert.Equal(t, types.ParameterTierAdvanced, (output.Parameters[0].Tier))
+}
+
+func TestFakeParameterLabels(t *testing.T) {
+	_, mgr := newFakeSystemManager(t)
+	labels := mgr.(awssystemmanager.ParameterInterface)
+
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{Value: "first"}))
+	require.NoError(t, labels.LabelSecretVersion(testRegion, "/app/token", "", "prod"))
+	require.NoError(t, mgr.SetSecret(testRegion, "/app/token", &secretstore.SecretValue{Value: "second", Overwrite: true}))
+	require.NoError(t, labels.LabelSecretVersion(testRegion, "/app/token", "2", "staging"))
+
+	prod, err := mgr.GetSecret(testRegion, "/app/token:prod", "")
+	require.NoError(t, err)
+	assert.Equal(t, "first", prod)
+	staging, err := mgr.GetSecret(testRegion, "/app/token:staging", "")
+	require.NoError(t, err)
+	assert.Equal(t, "second", staging)
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: testRegion, SecretName: "/app/token:prod"},
+		{Location: testRegion, SecretName: "/app/token"},
+	}, 2)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "first", results[0].Value)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "second", results[1].Value)
+
+	assert.Error(t, labels.LabelSecretVersion(testRegion, "/app/token", "1", "aws-reserved"))
+	_, err = mgr.GetSecret(testRegion, "/app/token:missing", "")
+	assert.True(t, secretstore.IsNotFound(err))
+}
+
+func TestFakeCreateSecret(t *testing.T) {
+	server, mgr := newFakeSystemManager(t)
+	server.PutParameter(testRegion, "/app/db", "SecureString", `{"username":"admin"}`)
+	server.PutParameter(testRegion, "/app/prod/db/username", "String", "admin")
+	creator, ok := mgr.(secretstore.CreateInterface)
+	require.True(t, ok)
+
+	err := creator.CreateSecret(testRegion, "/app/db", &secretstore.SecretValue{Value: "{}", Overwrite: true})
+	assert.True(t, secretstore.IsConflict(err))
+	err = creator.CreateSecret(testRegion, "/app/prod/db/", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"username": "root", "password": "first"},
+	})
+	assert.True(t, secretstore.IsConflict(err))
+	_, err = mgr.GetSecret(testRegion, "/app/prod/db/", "password")
+	assert.True(t, secretstore.IsNotFound(err), "nothing should be written when a property of the path exists")
+
+	for _, name := range []string{"/app/db", "/app/prod/db/"} {
+		password, created, err := generator.SetIfAbsent(mgr, testRegion, name, "password", func() (string, error) {
+			return "generated", nil
+		})
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, "generated", password)
+
+		value, err := mgr.GetSecret(testRegion, name, "")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"username":"admin","password":"generated"}`, value)
+	}
+}
@@ -0,0 +1,180 @@
+package awssystemmanager
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// DescriptionAnnotation is the annotation written as the description of a parameter
+const DescriptionAnnotation = "description"
+
+// WithParameterType sets the type of the parameters written, types.ParameterTypeSecureString by default. A KMS key can
+// only be used with SecureString parameters.
+func WithParameterType(parameterType string) Option {
+	return func(a *awsSystemManager) {
+		a.parameterType = parameterType
+	}
+}
+
+// WithKMSKeyID writes parameters as SecureString encrypted with the KMS key, by ID, ARN or alias, instead of the
+// AWS managed key
+func WithKMSKeyID(keyID string) Option {
+	return func(a *awsSystemManager) {
+		a.kmsKeyID = keyID
+	}
+}
+
+// WithTier sets the tier of the parameters written, one of types.ParameterTierStandard, types.ParameterTierAdvanced or
+// types.ParameterTierIntelligentTiering. Parameter Store uses the standard tier if none is set.
+func WithTier(tier string) Option {
+	return func(a *awsSystemManager) {
+		a.tier = tier
+	}
+}
+
+// putParameterInput returns the input to write a secret value as a parameter. Parameter Store can't tag a parameter
+// while overwriting it, so tags are only included when the parameter is created. Parameters with policies need the
+// advanced tier so are written with intelligent tiering unless a tier is set.
+func (a awsSystemManager) putParameterInput(secretName string, secretValue *secretstore.SecretValue) (*ssm.PutParameterInput, error) {
+	policies, err := a.parameterPolicies(secretValue.Lifecycle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid lifecycle of parameter %s", secretName)
+	}
+	parameterType := a.typeOf()
+	if a.kmsKeyID != "" && parameterType != types.ParameterTypeSecureString {
+		return nil, errors.Errorf("parameter %s can't be written as %s with a KMS key, which only encrypts SecureString parameters", secretName, parameterType)
+	}
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(secretName),
+		Value:     aws.String(secretValue.ToString()),
+		Type:      parameterType,
+		Overwrite: aws.Bool(secretValue.Overwrite),
+	}
+	if a.kmsKeyID != "" {
+		input.KeyId = aws.String(a.kmsKeyID)
+	}
+	if policies != "" {
+		input.Policies = aws.String(policies)
+		input.Tier = types.ParameterTierIntelligentTiering
+	}
+	if a.tier != "" {
+		input.Tier = types.ParameterTier(a.tier)
+	}
+	if description := secretValue.Annotations[DescriptionAnnotation]; description != "" {
+		input.Description = aws.String(description)
+	}
+	if !secretValue.Overwrite {
+		input.Tags = labelsToTags(secretValue.Labels)
+	}
+	return input, nil
+}
+
+func (a awsSystemManager) typeOf() types.ParameterType {
+	if a.parameterType != "" {
+		return types.ParameterType(a.parameterType)
+	}
+	return types.ParameterTypeSecureString
+}
+
+// updateTags merges labels into the tags of an existing parameter, removing only the tags with the keys in remove
+func updateTags(ctx context.Context, mgr *ssm.Client, secretName string, labels map[string]string, remove []string) error {
+	output, err := mgr.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String(secretName),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error listing tags of parameter %s", secretName)
+	}
+	existing := tagsToLabels(output.TagList)
+
+	var removed []string
+	for _, k := range remove {
+		if _, ok := existing[k]; !ok {
+			continue
+		}
+		if _, ok := labels[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+	if len(removed) > 0 {
+		_, err = mgr.RemoveTagsFromResource(ctx, &ssm.RemoveTagsFromResourceInput{
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			ResourceId:   aws.String(secretName),
+			TagKeys:      removed,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error removing tags from parameter %s", secretName)
+		}
+	}
+	changed := map[string]string{}
+	for k, v := range labels {
+		if current, ok := existing[k]; !ok || current != v {
+			changed[k] = v
+		}
+	}
+	if len(changed) > 0 {
+		_, err = mgr.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			ResourceId:   aws.String(secretName),
+			Tags:         labelsToTags(changed),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error tagging parameter %s", secretName)
+		}
+	}
+	return nil
+}
+
+// labelsToTags converts labels to tags ordered by key
+func labelsToTags(labels map[string]string) []types.Tag {
+	if len(labels) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]types.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(labels[k])})
+	}
+	return tags
+}
+
+func tagsToLabels(tags []types.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, t := range tags {
+		labels[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return labels
+}
+
+// notFoundError converts the error of a call about a parameter, or version of a parameter, that doesn't exist to a
+// secretstore.NotFoundError, and returns other errors unchanged
+func notFoundError(err error, location, secretName string) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ParameterNotFound", "ParameterVersionNotFound", "ParameterVersionLabelNotFound":
+			return &secretstore.NotFoundError{Location: location, SecretName: secretName, Err: err}
+		}
+	}
+	return err
+}
+
+func isAlreadyExists(err error) bool {
+	var exists *types.ParameterAlreadyExists
+	return errors.As(err, &exists)
+}
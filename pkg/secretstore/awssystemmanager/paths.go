@@ -0,0 +1,151 @@
+package awssystemmanager
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// maxDeleteParameters is the maximum number of names accepted by a single DeleteParameters call
+const maxDeleteParameters = 10
+
+// IsPath reports whether a secret name is a path, such as /app/prod/db/, ending in a slash. The parameters under a path
+// are read and written as the properties of one secret, keyed by their names relative to the path.
+func IsPath(secretName string) bool {
+	return strings.HasSuffix(secretName, "/")
+}
+
+// getPathSecret reads a property of a path, or all its properties as a JSON object if no key is given
+func (a awsSystemManager) getPathSecret(ctx context.Context, location, path, secretKey string) (string, error) {
+	mgr := a.client(location)
+	if secretKey != "" {
+		result, err := mgr.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(path + secretKey),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", errors.Wrapf(notFoundError(err, location, path), "error retrieving property %s of path %s from aws parameter store", secretKey, path)
+		}
+		return aws.ToString(result.Parameter.Value), nil
+	}
+
+	properties, err := getPathProperties(ctx, mgr, path)
+	if err != nil {
+		return "", err
+	}
+	if len(properties) == 0 {
+		return "", &secretstore.NotFoundError{Location: location, SecretName: path}
+	}
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return "", errors.Wrapf(err, "error marshalling parameters under path %s", path)
+	}
+	return string(data), nil
+}
+
+// getPathProperties reads every parameter under a path, keyed by its name relative to the path
+func getPathProperties(ctx context.Context, mgr *ssm.Client, path string) (map[string]string, error) {
+	properties := map[string]string{}
+	pages := ssm.NewGetParametersByPathPaginator(mgr, &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	})
+	for pages.HasMorePages() {
+		output, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error retrieving parameters under path %s from aws parameter store", path)
+		}
+		for _, p := range output.Parameters {
+			properties[strings.TrimPrefix(aws.ToString(p.Name), path)] = aws.ToString(p.Value)
+		}
+	}
+	return properties, nil
+}
+
+// setPathSecret writes each property of a secret value as a parameter under the path, replacing the parameters of
+// properties that already exist. Other parameters under the path are left alone unless Overwrite is set, in which case
+// the parameters that aren't properties are deleted. When create is set nothing is written if any of the properties
+// already exists, and a secretstore.ConflictError is returned instead.
+func (a awsSystemManager) setPathSecret(ctx context.Context, location, path string, secretValue *secretstore.SecretValue, create bool) error {
+	if secretValue.Value != "" || secretValue.BinaryValue != nil {
+		return errors.Errorf("path %s can only be written with property values", path)
+	}
+	keys := make([]string, 0, len(secretValue.PropertyValues))
+	for k := range secretValue.PropertyValues {
+		if k == "" || strings.HasPrefix(k, "/") {
+			return errors.Errorf("invalid property %q of path %s", k, path)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	mgr := a.client(location)
+	existing, err := getPathProperties(ctx, mgr, path)
+	if err != nil {
+		return err
+	}
+	if create {
+		for _, k := range keys {
+			if _, ok := existing[k]; ok {
+				return &secretstore.ConflictError{Location: location, SecretName: path}
+			}
+		}
+	}
+
+	for _, k := range keys {
+		name := path + k
+		property := *secretValue
+		property.Value = secretValue.PropertyValues[k]
+		property.PropertyValues = nil
+		// a path write replaces the properties it names whatever Overwrite is set to, as a merge into a secret does.
+		// Parameters can only be tagged when they are created, so existing ones are overwritten and tagged separately,
+		// merging the labels into their tags.
+		_, property.Overwrite = existing[k]
+		input, err := a.putParameterInput(name, &property)
+		if err != nil {
+			return err
+		}
+		_, err = mgr.PutParameter(ctx, input)
+		if err != nil {
+			if isAlreadyExists(err) {
+				return &secretstore.ConflictError{Location: location, SecretName: path, Err: err}
+			}
+			return errors.Wrapf(err, "error setting parameter %s for aws parameter store", name)
+		}
+		if property.Overwrite && (len(secretValue.Labels) > 0 || len(secretValue.RemoveLabels) > 0) {
+			err = updateTags(ctx, mgr, name, secretValue.Labels, secretValue.RemoveLabels)
+			if err != nil {
+				return errors.Wrapf(err, "error updating tags of parameter %s in aws parameter store", name)
+			}
+		}
+	}
+
+	if !secretValue.Overwrite {
+		return nil
+	}
+	var removed []string
+	for k := range existing {
+		if _, ok := secretValue.PropertyValues[k]; !ok {
+			removed = append(removed, path+k)
+		}
+	}
+	sort.Strings(removed)
+	for start := 0; start < len(removed); start += maxDeleteParameters {
+		end := start + maxDeleteParameters
+		if end > len(removed) {
+			end = len(removed)
+		}
+		_, err := mgr.DeleteParameters(ctx, &ssm.DeleteParametersInput{Names: removed[start:end]})
+		if err != nil {
+			return errors.Wrapf(err, "error deleting parameters under path %s from aws parameter store", path)
+		}
+	}
+	return nil
+}
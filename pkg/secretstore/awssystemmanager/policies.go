@@ -0,0 +1,163 @@
+package awssystemmanager
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Parameter policy types
+const (
+	PolicyExpiration             = "Expiration"
+	PolicyExpirationNotification = "ExpirationNotification"
+	PolicyNoChangeNotification   = "NoChangeNotification"
+)
+
+// ParameterPolicy is a policy attached to a parameter
+type ParameterPolicy struct {
+	Type string
+	// Text is the JSON of the policy
+	Text string
+	// Status is Pending, Finished, InProgress or Failed
+	Status string
+}
+
+// ParameterInterface manages the version labels and reads the policies of parameters. It is implemented by the
+// secret manager returned by NewAwsSystemManager. A labelled version is read by GetSecret with a name:label selector.
+type ParameterInterface interface {
+	// LabelSecretVersion moves labels to a version of a parameter, the latest if version is empty
+	LabelSecretVersion(location, secretName, version string, labels ...string) error
+	GetSecretPolicies(location, secretName string) ([]ParameterPolicy, error)
+
+	LabelSecretVersionWithContext(ctx context.Context, location, secretName, version string, labels ...string) error
+	GetSecretPoliciesWithContext(ctx context.Context, location, secretName string) ([]ParameterPolicy, error)
+}
+
+// WithExpirationNotification sends an EventBridge notification the given time before a parameter written with an
+// expiry expires. It is rounded down to whole hours.
+func WithExpirationNotification(before time.Duration) Option {
+	return func(a *awsSystemManager) {
+		a.expirationNotification = before
+	}
+}
+
+// parameterPolicies returns the JSON policies of a parameter written with a lifecycle. ExpireTime or TTL are written
+// as an Expiration policy, and RotationPeriod as a NoChangeNotification policy which notifies when the parameter isn't
+// rotated in time. An empty string is returned if there are no policies.
+func (a awsSystemManager) parameterPolicies(lifecycle *secretstore.SecretLifecycle) (string, error) {
+	if lifecycle == nil {
+		return "", nil
+	}
+	if !lifecycle.ExpireTime.IsZero() && lifecycle.TTL != 0 {
+		return "", errors.New("only one of the expire time and TTL of a secret can be set")
+	}
+	if lifecycle.TTL < 0 || lifecycle.RotationPeriod < 0 {
+		return "", errors.New("the TTL and rotation period of a secret can't be negative")
+	}
+
+	var policies []interface{}
+	expireTime := lifecycle.ExpireTime
+	if lifecycle.TTL != 0 {
+		expireTime = time.Now().Add(lifecycle.TTL)
+	}
+	if !expireTime.IsZero() {
+		policies = append(policies, policy(PolicyExpiration, map[string]string{
+			"Timestamp": expireTime.UTC().Format(time.RFC3339),
+		}))
+		if hours := int(a.expirationNotification / time.Hour); hours > 0 {
+			policies = append(policies, policy(PolicyExpirationNotification, map[string]string{
+				"Before": strconv.Itoa(hours),
+				"Unit":   "Hours",
+			}))
+		}
+	}
+	if hours := int(lifecycle.RotationPeriod / time.Hour); hours > 0 {
+		policies = append(policies, policy(PolicyNoChangeNotification, map[string]string{
+			"After": strconv.Itoa(hours),
+			"Unit":  "Hours",
+		}))
+	}
+	if len(policies) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(policies)
+	if err != nil {
+		return "", errors.Wrap(err, "error marshalling parameter policies")
+	}
+	return string(data), nil
+}
+
+func policy(policyType string, attributes map[string]string) interface{} {
+	return map[string]interface{}{
+		"Type":       policyType,
+		"Version":    "1.0",
+		"Attributes": attributes,
+	}
+}
+
+func (a awsSystemManager) LabelSecretVersion(location, secretName, version string, labels ...string) error {
+	return a.LabelSecretVersionWithContext(context.TODO(), location, secretName, version, labels...)
+}
+
+func (a awsSystemManager) LabelSecretVersionWithContext(ctx context.Context, location, secretName, version string, labels ...string) error {
+	input := &ssm.LabelParameterVersionInput{
+		Name:   aws.String(secretName),
+		Labels: labels,
+	}
+	if version != "" {
+		n, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid version %s of parameter %s", version, secretName)
+		}
+		input.ParameterVersion = aws.Int64(n)
+	}
+	output, err := a.client(location).LabelParameterVersion(ctx, input)
+	if err != nil {
+		return errors.Wrapf(err, "error labelling version of parameter %s in aws parameter store", secretName)
+	}
+	if len(output.InvalidLabels) > 0 {
+		return errors.Errorf("invalid labels %v for parameter %s", output.InvalidLabels, secretName)
+	}
+	return nil
+}
+
+// GetSecretPolicies returns the policies of the latest version of a parameter
+func (a awsSystemManager) GetSecretPolicies(location, secretName string) ([]ParameterPolicy, error) {
+	return a.GetSecretPoliciesWithContext(context.TODO(), location, secretName)
+}
+
+// GetSecretPoliciesWithContext returns the policies like GetSecretPolicies with the context of the requests
+func (a awsSystemManager) GetSecretPoliciesWithContext(ctx context.Context, location, secretName string) ([]ParameterPolicy, error) {
+	var latest *types.ParameterHistory
+	pages := ssm.NewGetParameterHistoryPaginator(a.client(location), &ssm.GetParameterHistoryInput{Name: aws.String(secretName)})
+	for pages.HasMorePages() {
+		output, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(notFoundError(err, location, secretName), "error retrieving history of parameter %s from aws parameter store", secretName)
+		}
+		for i := range output.Parameters {
+			if h := &output.Parameters[i]; latest == nil || h.Version > latest.Version {
+				latest = h
+			}
+		}
+	}
+	if latest == nil {
+		return nil, &secretstore.NotFoundError{Location: location, SecretName: secretName}
+	}
+	policies := make([]ParameterPolicy, 0, len(latest.Policies))
+	for _, p := range latest.Policies {
+		policies = append(policies, ParameterPolicy{
+			Type:   aws.ToString(p.PolicyType),
+			Text:   aws.ToString(p.PolicyText),
+			Status: aws.ToString(p.PolicyStatus),
+		})
+	}
+	return policies, nil
+}
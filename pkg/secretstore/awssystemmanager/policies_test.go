@@ -0,0 +1,34 @@
+package awssystemmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParameterPolicies(t *testing.T) {
+	mgr := NewAwsSystemManager(nil, WithExpirationNotification(48*time.Hour)).(awsSystemManager)
+
+	policies, err := mgr.parameterPolicies(nil)
+	require.NoError(t, err)
+	assert.Empty(t, policies)
+
+	policies, err = mgr.parameterPolicies(&secretstore.SecretLifecycle{
+		ExpireTime:     time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		RotationPeriod: 30 * 24 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"Type":"Expiration","Version":"1.0","Attributes":{"Timestamp":"2030-01-02T03:04:05Z"}},
+		{"Type":"ExpirationNotification","Version":"1.0","Attributes":{"Before":"48","Unit":"Hours"}},
+		{"Type":"NoChangeNotification","Version":"1.0","Attributes":{"After":"720","Unit":"Hours"}}
+	]`, policies)
+
+	_, err = mgr.parameterPolicies(&secretstore.SecretLifecycle{ExpireTime: time.Now(), TTL: time.Hour})
+	assert.Error(t, err)
+	_, err = mgr.parameterPolicies(&secretstore.SecretLifecycle{TTL: -time.Hour})
+	assert.Error(t, err)
+}
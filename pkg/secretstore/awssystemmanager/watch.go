@@ -0,0 +1,26 @@
+package awssystemmanager
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Watch polls the version of the parameter
+func (a awsSystemManager) Watch(location, secretName string) (secretstore.Watcher, error) {
+	return secretstore.NewPollingWatcherWithContext(location, secretName, secretstore.DefaultWatchPollInterval, func(ctx context.Context) (string, error) {
+		return a.parameterVersion(ctx, location, secretName)
+	}), nil
+}
+
+func (a awsSystemManager) parameterVersion(ctx context.Context, location, secretName string) (string, error) {
+	result, err := a.client(location).GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(secretName)})
+	if err != nil {
+		return "", errors.Wrap(notFoundError(err, location, secretName), "error retrieving secret from aws parameter store")
+	}
+	return strconv.FormatInt(result.Parameter.Version, 10), nil
+}
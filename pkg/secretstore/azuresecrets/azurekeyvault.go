@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	kvops "github.com/Azure/azure-sdk-for-go/services/keyvault/v7.1/keyvault"
+	"github.com/Azure/go-autorest/autorest"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/azureiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
 	"github.com/pkg/errors"
@@ -31,7 +33,7 @@ func (a *azureKeyVaultSecretManager) GetSecret(vaultName, secretName, secretKey
 	}
 	bundle, err := keyClient.GetSecret(context.TODO(), vaultURL.String(), secretName, "")
 	if err != nil {
-		return "", errors.Wrapf(err, "unable to retrieve secret %s from vault %s", secretName, vaultURL)
+		return "", errors.Wrapf(notFoundError(err, vaultName, secretName), "unable to retrieve secret %s from vault %s", secretName, vaultURL)
 	}
 	if bundle.Value == nil {
 		return "", errors.Wrapf(err, "secret is empty for secret %s in vault %s", secretName, vaultURL)
@@ -58,18 +60,65 @@ func (a *azureKeyVaultSecretManager) SetSecret(vaultName, secretName string, sec
 		return errors.Wrap(err, "unable to create key ops client")
 	}
 	secretString := secretValue.ToString()
+	if mergesProperties(secretValue) {
+		bundle, err := keyClient.GetSecret(context.TODO(), vaultURL.String(), secretName, "")
+		switch {
+		case err == nil:
+			secretString, err = mergeSecretValue(bundle.Value, secretValue)
+			if err != nil {
+				return errors.Wrapf(err, "unable to merge properties into secret %s in vault %s", secretName, vaultURL)
+			}
+		case !isNotFound(err):
+			return errors.Wrapf(err, "unable to retrieve existing secret %s from vault %s", secretName, vaultURL)
+		}
+	}
 	params := kvops.SecretSetParameters{
 		Value: &secretString,
 	}
 	_, err = keyClient.SetSecret(context.TODO(), vaultURL.String(), secretName, params)
 
 	if err != nil {
-		return errors.Wrap(err, "unable to create key ops client")
+		return errors.Wrapf(err, "unable to set secret %s in vault %s", secretName, vaultURL)
 	}
 
 	return nil
 }
 
+// mergesProperties reports whether a secret value is written by merging its properties into those of the existing
+// secret, which is the case unless it has a plain or binary value or Overwrite is set
+func mergesProperties(secretValue *secretstore.SecretValue) bool {
+	return !secretValue.Overwrite && secretValue.Value == "" && secretValue.BinaryValue == nil && secretValue.PropertyValues != nil
+}
+
+// mergeSecretValue returns the JSON of the existing properties of a secret updated with the property values. An empty
+// existing secret is replaced, but one that isn't a JSON object of strings can only be replaced with Overwrite.
+func mergeSecretValue(existing *string, secretValue *secretstore.SecretValue) (string, error) {
+	if existing == nil || *existing == "" {
+		return secretValue.ToString(), nil
+	}
+	properties := map[string]string{}
+	err := json.Unmarshal([]byte(*existing), &properties)
+	if err != nil {
+		return "", errors.Wrap(err, "existing secret isn't a JSON object of strings, set Overwrite to replace it")
+	}
+	return secretValue.MergeExistingSecret(properties), nil
+}
+
+// isNotFound reports whether a Key Vault call failed because the secret doesn't exist
+func isNotFound(err error) bool {
+	var detailedErr autorest.DetailedError
+	return errors.As(err, &detailedErr) && detailedErr.StatusCode == http.StatusNotFound
+}
+
+// notFoundError converts the error of a call about a secret that doesn't exist to a secretstore.NotFoundError, and
+// returns other errors unchanged
+func notFoundError(err error, vaultName, secretName string) error {
+	if isNotFound(err) {
+		return &secretstore.NotFoundError{Location: vaultName, SecretName: secretName, Err: err}
+	}
+	return err
+}
+
 func getSecretPropertyMap(v kvops.SecretBundle) (map[string]string, error) {
 	m := make(map[string]string)
 	secretString := *v.Value
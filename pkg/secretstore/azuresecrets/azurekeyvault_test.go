@@ -33,4 +33,14 @@ func TestAzureKey(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "thisisausername", username)
 
+	err = secretMgr.SetSecret(keyVaultName, "testsecret", &secretstore.SecretValue{
+		PropertyValues: map[string]string{
+			"password": "thisisanotherpassword",
+		},
+	})
+	assert.NoError(t, err)
+
+	username, err = secretMgr.GetSecret(keyVaultName, "testsecret", "username")
+	assert.NoError(t, err)
+	assert.Equal(t, "thisisausername", username)
 }
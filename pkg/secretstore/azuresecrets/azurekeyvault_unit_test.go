@@ -0,0 +1,49 @@
+package azuresecrets
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergesProperties(t *testing.T) {
+	properties := map[string]string{"password": "secret"}
+	assert.True(t, mergesProperties(&secretstore.SecretValue{PropertyValues: properties}))
+	assert.False(t, mergesProperties(&secretstore.SecretValue{PropertyValues: properties, Overwrite: true}))
+	assert.False(t, mergesProperties(&secretstore.SecretValue{Value: "token"}))
+	assert.False(t, mergesProperties(&secretstore.SecretValue{BinaryValue: []byte{0x01}}))
+}
+
+func TestMergeSecretValue(t *testing.T) {
+	secretValue := &secretstore.SecretValue{PropertyValues: map[string]string{"password": "new"}}
+
+	existing := `{"username":"admin","password":"old"}`
+	merged, err := mergeSecretValue(&existing, secretValue)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"username":"admin","password":"new"}`, merged)
+
+	merged, err = mergeSecretValue(nil, secretValue)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"new"}`, merged)
+
+	empty := ""
+	merged, err = mergeSecretValue(&empty, secretValue)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"new"}`, merged)
+
+	plain := "not json"
+	_, err = mergeSecretValue(&plain, secretValue)
+	assert.Error(t, err)
+}
+
+func TestNotFoundError(t *testing.T) {
+	err := notFoundError(autorest.DetailedError{StatusCode: http.StatusNotFound}, "vault", "db")
+	assert.True(t, secretstore.IsNotFound(err))
+
+	err = notFoundError(autorest.DetailedError{StatusCode: http.StatusForbidden}, "vault", "db")
+	assert.False(t, secretstore.IsNotFound(err))
+}
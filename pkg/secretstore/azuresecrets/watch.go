@@ -0,0 +1,32 @@
+package azuresecrets
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Watch polls the current version of the secret, taken from the last segment of its identifier
+func (a *azureKeyVaultSecretManager) Watch(vaultName, secretName string) (secretstore.Watcher, error) {
+	return secretstore.NewPollingWatcher(vaultName, secretName, secretstore.DefaultWatchPollInterval, func() (string, error) {
+		return a.currentVersion(vaultName, secretName)
+	}), nil
+}
+
+func (a *azureKeyVaultSecretManager) currentVersion(vaultName, secretName string) (string, error) {
+	keyClient, err := getSecretOpsClient(a.Creds)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create key ops client")
+	}
+	bundle, err := keyClient.GetSecret(context.TODO(), fmt.Sprintf("https://%s.vault.azure.net/", vaultName), secretName, "")
+	if err != nil {
+		return "", errors.Wrapf(notFoundError(err, vaultName, secretName), "unable to retrieve secret %s from vault %s", secretName, vaultName)
+	}
+	if bundle.ID == nil {
+		return "", fmt.Errorf("secret %s in vault %s has no identifier", secretName, vaultName)
+	}
+	return path.Base(*bundle.ID), nil
+}
@@ -0,0 +1,173 @@
+package secretstore
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBatchConcurrency is the number of concurrent requests used by batch operations when none is specified
+const DefaultBatchConcurrency = 10
+
+// SecretRef identifies a secret, or a key within a secret, to read as part of a batch
+type SecretRef struct {
+	Location   string
+	SecretName string
+	SecretKey  string
+}
+
+// SecretWrite is a secret to write as part of a batch
+type SecretWrite struct {
+	Location    string
+	SecretName  string
+	SecretValue *SecretValue
+}
+
+// GetResult is the outcome of reading a single SecretRef
+type GetResult struct {
+	Ref   SecretRef
+	Value string
+	Err   error
+}
+
+// SetResult is the outcome of a single SecretWrite
+type SetResult struct {
+	Write SecretWrite
+	Err   error
+}
+
+// BatchInterface is implemented by secret managers with native support for reading or writing many secrets at once.
+// Results are returned in the same order as the refs or writes, and a failure of one item does not fail the others.
+type BatchInterface interface {
+	GetSecrets(refs []SecretRef, concurrency int) []GetResult
+	SetSecrets(writes []SecretWrite, concurrency int) []SetResult
+}
+
+// BatchContextInterface is implemented by secret managers with native batch support whose requests can be cancelled, or
+// given a deadline, with a context
+type BatchContextInterface interface {
+	GetSecretsWithContext(ctx context.Context, refs []SecretRef, concurrency int) []GetResult
+	SetSecretsWithContext(ctx context.Context, writes []SecretWrite, concurrency int) []SetResult
+}
+
+// GetSecrets reads many secrets with at most concurrency requests in flight, using the secret manager's native batch
+// support when it has any
+func GetSecrets(mgr Interface, refs []SecretRef, concurrency int) []GetResult {
+	if batchMgr, ok := mgr.(BatchInterface); ok {
+		return batchMgr.GetSecrets(refs, concurrency)
+	}
+	return ParallelGetSecrets(mgr, refs, concurrency)
+}
+
+// SetSecrets writes many secrets with at most concurrency requests in flight, using the secret manager's native batch
+// support when it has any
+func SetSecrets(mgr Interface, writes []SecretWrite, concurrency int) []SetResult {
+	if batchMgr, ok := mgr.(BatchInterface); ok {
+		return batchMgr.SetSecrets(writes, concurrency)
+	}
+	return ParallelSetSecrets(mgr, writes, concurrency)
+}
+
+// GetSecretsWithContext reads many secrets like GetSecrets, with the context if the secret manager supports one
+func GetSecretsWithContext(ctx context.Context, mgr Interface, refs []SecretRef, concurrency int) []GetResult {
+	if batchMgr, ok := mgr.(BatchContextInterface); ok {
+		return batchMgr.GetSecretsWithContext(ctx, refs, concurrency)
+	}
+	if batchMgr, ok := mgr.(BatchInterface); ok && ctx.Err() == nil {
+		return batchMgr.GetSecrets(refs, concurrency)
+	}
+	return ParallelGetSecretsWithContext(ctx, mgr, refs, concurrency)
+}
+
+// SetSecretsWithContext writes many secrets like SetSecrets, with the context if the secret manager supports one
+func SetSecretsWithContext(ctx context.Context, mgr Interface, writes []SecretWrite, concurrency int) []SetResult {
+	if batchMgr, ok := mgr.(BatchContextInterface); ok {
+		return batchMgr.SetSecretsWithContext(ctx, writes, concurrency)
+	}
+	if batchMgr, ok := mgr.(BatchInterface); ok && ctx.Err() == nil {
+		return batchMgr.SetSecrets(writes, concurrency)
+	}
+	return ParallelSetSecretsWithContext(ctx, mgr, writes, concurrency)
+}
+
+// ParallelGetSecrets reads many secrets by calling GetSecret from a pool of concurrency workers
+func ParallelGetSecrets(mgr Interface, refs []SecretRef, concurrency int) []GetResult {
+	results := make([]GetResult, len(refs))
+	Parallel(len(refs), concurrency, func(i int) {
+		ref := refs[i]
+		value, err := mgr.GetSecret(ref.Location, ref.SecretName, ref.SecretKey)
+		results[i] = GetResult{Ref: ref, Value: value, Err: err}
+	})
+	return results
+}
+
+// ParallelGetSecretsWithContext reads many secrets by calling GetSecretWithContext from a pool of concurrency workers
+func ParallelGetSecretsWithContext(ctx context.Context, mgr Interface, refs []SecretRef, concurrency int) []GetResult {
+	results := make([]GetResult, len(refs))
+	Parallel(len(refs), concurrency, func(i int) {
+		ref := refs[i]
+		value, err := GetSecretWithContext(ctx, mgr, ref.Location, ref.SecretName, ref.SecretKey)
+		results[i] = GetResult{Ref: ref, Value: value, Err: err}
+	})
+	return results
+}
+
+// ParallelSetSecrets writes many secrets by calling SetSecret from a pool of concurrency workers
+func ParallelSetSecrets(mgr Interface, writes []SecretWrite, concurrency int) []SetResult {
+	results := make([]SetResult, len(writes))
+	Parallel(len(writes), concurrency, func(i int) {
+		write := writes[i]
+		results[i] = SetResult{Write: write, Err: mgr.SetSecret(write.Location, write.SecretName, write.SecretValue)}
+	})
+	return results
+}
+
+// ParallelSetSecretsWithContext writes many secrets by calling SetSecretWithContext from a pool of concurrency workers
+func ParallelSetSecretsWithContext(ctx context.Context, mgr Interface, writes []SecretWrite, concurrency int) []SetResult {
+	results := make([]SetResult, len(writes))
+	Parallel(len(writes), concurrency, func(i int) {
+		write := writes[i]
+		results[i] = SetResult{Write: write, Err: SetSecretWithContext(ctx, mgr, write.Location, write.SecretName, write.SecretValue)}
+	})
+	return results
+}
+
+// GroupRefsByLocation returns the distinct locations of the refs in the order they first appear, and the indexes of
+// the refs at each location
+func GroupRefsByLocation(refs []SecretRef) ([]string, map[string][]int) {
+	var locations []string
+	byLocation := map[string][]int{}
+	for i, ref := range refs {
+		if _, ok := byLocation[ref.Location]; !ok {
+			locations = append(locations, ref.Location)
+		}
+		byLocation[ref.Location] = append(byLocation[ref.Location], i)
+	}
+	return locations, byLocation
+}
+
+// Parallel calls fn for every index in [0, n) from a pool of concurrency workers and waits for them to finish. A
+// concurrency of zero or less uses DefaultBatchConcurrency.
+func Parallel(n, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,63 @@
+package secretstore_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelSecrets(t *testing.T) {
+	store := fake.NewFakeSecretStore()
+	var writes []secretstore.SecretWrite
+	var refs []secretstore.SecretRef
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("secret-%d", i)
+		writes = append(writes, secretstore.SecretWrite{
+			Location:    "loc",
+			SecretName:  name,
+			SecretValue: &secretstore.SecretValue{PropertyValues: map[string]string{"key": name}},
+		})
+		refs = append(refs, secretstore.SecretRef{Location: "loc", SecretName: name, SecretKey: "key"})
+	}
+
+	mgr := &countingSecretStore{Interface: store}
+	// the fake store is not safe for concurrent writes so write serially
+	for _, r := range secretstore.SetSecrets(mgr, writes, 1) {
+		require.NoError(t, r.Err)
+	}
+
+	results := secretstore.GetSecrets(mgr, refs, 8)
+	require.Len(t, results, len(refs))
+	for i, r := range results {
+		assert.NoError(t, r.Err)
+		assert.Equal(t, refs[i], r.Ref)
+		assert.Equal(t, refs[i].SecretName, r.Value)
+	}
+	assert.Equal(t, int32(len(refs)), mgr.gets)
+	assert.LessOrEqual(t, mgr.maxInFlight, int32(8))
+}
+
+type countingSecretStore struct {
+	secretstore.Interface
+	gets        int32
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (c *countingSecretStore) GetSecret(location, secretName, secretKey string) (string, error) {
+	atomic.AddInt32(&c.gets, 1)
+	n := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+	for {
+		maxInFlight := atomic.LoadInt32(&c.maxInFlight)
+		if n <= maxInFlight || atomic.CompareAndSwapInt32(&c.maxInFlight, maxInFlight, n) {
+			break
+		}
+	}
+	return c.Interface.GetSecret(location, secretName, secretKey)
+}
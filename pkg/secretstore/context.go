@@ -0,0 +1,32 @@
+package secretstore
+
+import "context"
+
+// ContextInterface is implemented by secret managers whose requests can be cancelled, or given a deadline, with a
+// context
+type ContextInterface interface {
+	GetSecretWithContext(ctx context.Context, location string, secretName string, secretKey string) (string, error)
+	SetSecretWithContext(ctx context.Context, location string, secretName string, secretValue *SecretValue) error
+}
+
+// GetSecretWithContext reads a secret with the context if the secret manager supports one, and without it otherwise
+func GetSecretWithContext(ctx context.Context, mgr Interface, location, secretName, secretKey string) (string, error) {
+	if ctxMgr, ok := mgr.(ContextInterface); ok {
+		return ctxMgr.GetSecretWithContext(ctx, location, secretName, secretKey)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return mgr.GetSecret(location, secretName, secretKey)
+}
+
+// SetSecretWithContext writes a secret with the context if the secret manager supports one, and without it otherwise
+func SetSecretWithContext(ctx context.Context, mgr Interface, location, secretName string, secretValue *SecretValue) error {
+	if ctxMgr, ok := mgr.(ContextInterface); ok {
+		return ctxMgr.SetSecretWithContext(ctx, location, secretName, secretValue)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return mgr.SetSecret(location, secretName, secretValue)
+}
@@ -0,0 +1,30 @@
+package secretstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretWithContextFallsBack(t *testing.T) {
+	store := fake.NewFakeSecretStore()
+	ctx := context.Background()
+
+	err := secretstore.SetSecretWithContext(ctx, store, "ns", "db", &secretstore.SecretValue{Value: "s3cr3t"})
+	require.NoError(t, err)
+	value, err := secretstore.GetSecretWithContext(ctx, store, "ns", "db", "")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = secretstore.GetSecretWithContext(cancelled, store, "ns", "db", "")
+	assert.ErrorIs(t, err, context.Canceled)
+	err = secretstore.SetSecretWithContext(cancelled, store, "ns", "db", &secretstore.SecretValue{Value: "other"})
+	assert.ErrorIs(t, err, context.Canceled)
+	store.AssertValueEquals(t, "ns", "db", "", "s3cr3t")
+}
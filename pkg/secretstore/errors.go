@@ -0,0 +1,65 @@
+package secretstore
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// NotFoundError is returned when a secret, or a key within a secret, does not exist. Secret managers convert the not
+// found errors of their secret store to a NotFoundError.
+type NotFoundError struct {
+	Location   string
+	SecretName string
+	SecretKey  string
+	// Err is the error returned by the secret store, if any
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	if e.SecretKey != "" {
+		return fmt.Sprintf("key %s not found in secret %s at location %s", e.SecretKey, e.SecretName, e.Location)
+	}
+	return fmt.Sprintf("secret %s not found at location %s", e.SecretName, e.Location)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether err, or any error it wraps, is a NotFoundError
+func IsNotFound(err error) bool {
+	var notFound *NotFoundError
+	return errors.As(err, &notFound)
+}
+
+// ConflictError is returned when a secret was modified concurrently while it was being updated, and the update could
+// not be applied on top of the concurrent modification, or when a secret created with CreateInterface already exists
+type ConflictError struct {
+	Location   string
+	SecretName string
+	// Attempts is the number of attempts at applying the update, or zero when the secret already existed
+	Attempts int
+	// Err is the error returned by the secret store, if any
+	Err error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Attempts == 0 && e.Err != nil {
+		return fmt.Sprintf("secret %s already exists at location %s: %v", e.SecretName, e.Location, e.Err)
+	}
+	if e.Attempts == 0 {
+		return fmt.Sprintf("secret %s already exists at location %s", e.SecretName, e.Location)
+	}
+	return fmt.Sprintf("secret %s at location %s was modified concurrently, giving up after %d attempts", e.SecretName, e.Location, e.Attempts)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// IsConflict reports whether err, or any error it wraps, is a ConflictError
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
@@ -1,11 +1,13 @@
 package factory
 
 import (
+	"context"
 	"fmt"
 	"os"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/hashicorp/vault/api"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/awsiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/azureiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/gcpiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/kubernetesiam"
@@ -20,7 +22,11 @@ import (
 	"github.com/pkg/errors"
 )
 
-type SecretManagerFactory struct{}
+type SecretManagerFactory struct {
+	// AwsCreds are the credentials of the AWS secret managers by type, so each can use a different account. Types
+	// without credentials read them from environment variables prefixed with AWS_SECRETSMANAGER_ or AWS_SSM_.
+	AwsCreds map[secretstore.Type]awsiam.AwsCreds
+}
 
 func (smf SecretManagerFactory) NewSecretManager(storeType secretstore.Type) (secretstore.Interface, error) {
 	switch storeType {
@@ -79,17 +85,45 @@ func (smf SecretManagerFactory) NewSecretManager(storeType secretstore.Type) (se
 		client.SetToken(creds.Token)
 		return vaultsecrets.NewVaultSecretManager(client)
 	case secretstore.SecretStoreTypeAwsASM:
-		sess, err := session.NewSession()
+		config, err := smf.awsConfig(storeType, "AWS_SECRETSMANAGER_")
 		if err != nil {
 			return nil, errors.Wrap(err, "error getting AWS creds when attempting to create secret manager via factory")
 		}
-		return awssecretsmanager.NewAwsSecretManager(sess), nil
+		var opts []awssecretsmanager.Option
+		if keyID := os.Getenv("AWS_SECRETSMANAGER_KMS_KEY_ID"); keyID != "" {
+			opts = append(opts, awssecretsmanager.WithKMSKeyID(keyID))
+		}
+		if policy := os.Getenv("AWS_SECRETSMANAGER_CONVERSION_POLICY"); policy != "" {
+			opts = append(opts, awssecretsmanager.WithConversionPolicy(awssecretsmanager.ConversionPolicy(policy)))
+		}
+		if key := os.Getenv("AWS_SECRETSMANAGER_WRAP_KEY"); key != "" {
+			opts = append(opts, awssecretsmanager.WithWrapKey(key))
+		}
+		return awssecretsmanager.NewAwsSecretManagerFromConfig(config, opts...), nil
 	case secretstore.SecretStoreTypeAwsSSM:
-		sess, err := session.NewSession()
+		config, err := smf.awsConfig(storeType, "AWS_SSM_")
 		if err != nil {
 			return nil, errors.Wrap(err, "error getting AWS creds when attempting to create secret manager via factory")
 		}
-		return awssystemmanager.NewAwsSystemManager(sess), nil
+		var opts []awssystemmanager.Option
+		if keyID := os.Getenv("AWS_SSM_KMS_KEY_ID"); keyID != "" {
+			opts = append(opts, awssystemmanager.WithKMSKeyID(keyID))
+		}
+		if tier := os.Getenv("AWS_SSM_TIER"); tier != "" {
+			opts = append(opts, awssystemmanager.WithTier(tier))
+		}
+		if parameterType := os.Getenv("AWS_SSM_PARAMETER_TYPE"); parameterType != "" {
+			opts = append(opts, awssystemmanager.WithParameterType(parameterType))
+		}
+		return awssystemmanager.NewAwsSystemManagerFromConfig(config, opts...), nil
 	}
 	return nil, fmt.Errorf("unable to create manager for storeType %s", string(storeType))
 }
+
+func (smf SecretManagerFactory) awsConfig(storeType secretstore.Type, envPrefix string) (aws.Config, error) {
+	creds, ok := smf.AwsCreds[storeType]
+	if !ok {
+		creds = awsiam.NewEnvironmentCreds(envPrefix)
+	}
+	return awsiam.NewConfig(context.TODO(), creds)
+}
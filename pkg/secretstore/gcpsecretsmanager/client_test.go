@@ -0,0 +1,46 @@
+package gcpsecretsmanager
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func TestClientIsSharedUntilClosed(t *testing.T) {
+	creds := google.Credentials{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})}
+	mgr := NewGcpSecretsManager(creds)
+	g := mgr.(*gcpSecretsManager)
+
+	clients := make([]*secretmanager.Client, 10)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client, err := g.getClient()
+			assert.NoError(t, err)
+			clients[i] = client
+		}(i)
+	}
+	wg.Wait()
+	for _, c := range clients {
+		require.NotNil(t, c)
+		assert.Same(t, clients[0], c)
+	}
+
+	closer, ok := mgr.(io.Closer)
+	require.True(t, ok)
+	require.NoError(t, closer.Close())
+	require.NoError(t, closer.Close())
+
+	client, err := g.getClient()
+	require.NoError(t, err)
+	assert.NotSame(t, clients[0], client)
+	require.NoError(t, closer.Close())
+}
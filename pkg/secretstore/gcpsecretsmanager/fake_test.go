@@ -0,0 +1,334 @@
+package gcpsecretsmanager_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/gcpsecretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fakegcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/google"
+)
+
+const testProject = "test-project"
+
+func newFakeSecretManager(t *testing.T, opts ...gcpsecretsmanager.Option) (secretstore.Interface, *fakegcp.SecretManagerServer) {
+	server, err := fakegcp.NewSecretManagerServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Stop)
+
+	opts = append([]gcpsecretsmanager.Option{
+		gcpsecretsmanager.WithEndpoint(server.Addr()),
+		gcpsecretsmanager.WithInsecure(),
+	}, opts...)
+	mgr := gcpsecretsmanager.NewGcpSecretsManager(google.Credentials{}, opts...)
+	t.Cleanup(func() {
+		_ = mgr.(io.Closer).Close()
+	})
+	return mgr, server
+}
+
+func TestFakeSetAndGetSecret(t *testing.T) {
+	mgr, server := newFakeSecretManager(t)
+
+	err := mgr.SetSecret(testProject, "token", &secretstore.SecretValue{Value: "first"})
+	require.NoError(t, err)
+	err = mgr.SetSecret(testProject, "token", &secretstore.SecretValue{Value: "second"})
+	require.NoError(t, err)
+
+	value, err := mgr.GetSecret(testProject, "token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "second", value)
+	assert.Len(t, server.Versions("projects/test-project/secrets/token"), 2)
+
+	_, err = mgr.GetSecret(testProject, "missing", "")
+	require.Error(t, err)
+	assert.True(t, secretstore.IsNotFound(err))
+}
+
+func TestFakeMergesPropertyValues(t *testing.T) {
+	mgr, _ := newFakeSecretManager(t)
+
+	err := mgr.SetSecret(testProject, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"username": "admin", "password": "first"},
+	})
+	require.NoError(t, err)
+	err = mgr.SetSecret(testProject, "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "second"},
+	})
+	require.NoError(t, err)
+
+	username, err := mgr.GetSecret(testProject, "db", "username")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+	password, err := mgr.GetSecret(testProject, "db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "second", password)
+}
+
+func TestFakeLabelsAndAnnotations(t *testing.T) {
+	mgr, _ := newFakeSecretManager(t)
+
+	err := mgr.SetSecret(testProject, "api", &secretstore.SecretValue{
+		Value:       "v1",
+		Labels:      map[string]string{"team": "platform"},
+		Annotations: map[string]string{"owner": "alice@example.com"},
+	})
+	require.NoError(t, err)
+	err = mgr.SetSecret(testProject, "api", &secretstore.SecretValue{
+		Value:       "v2",
+		Annotations: map[string]string{"purpose": "ci"},
+	})
+	require.NoError(t, err)
+
+	metadata, err := mgr.(secretstore.MetadataInterface).GetSecretMetadata(testProject, "api")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "platform"}, metadata.Labels)
+	assert.Equal(t, map[string]string{"owner": "alice@example.com", "purpose": "ci"}, metadata.Annotations)
+	assert.Equal(t, "2", metadata.Version)
+}
+
+func TestFakeReplicationPolicy(t *testing.T) {
+	var drifts []*gcpsecretsmanager.ReplicationDrift
+	mgr, server := newFakeSecretManager(t,
+		gcpsecretsmanager.WithSecretReplicationPolicy("pinned", gcpsecretsmanager.ReplicationPolicy{
+			Locations: []string{"europe-west4", "europe-west1"},
+		}),
+		gcpsecretsmanager.WithDriftHandler(func(drift *gcpsecretsmanager.ReplicationDrift) {
+			drifts = append(drifts, drift)
+		}),
+	)
+
+	require.NoError(t, mgr.SetSecret(testProject, "pinned", &secretstore.SecretValue{Value: "v"}))
+	require.NoError(t, mgr.SetSecret(testProject, "pinned", &secretstore.SecretValue{Value: "v"}))
+	assert.Empty(t, drifts)
+
+	replicas := server.Secret("projects/test-project/secrets/pinned").GetReplication().GetUserManaged().GetReplicas()
+	require.Len(t, replicas, 2)
+	assert.Equal(t, "europe-west1", replicas[0].Location)
+	assert.Equal(t, "europe-west4", replicas[1].Location)
+}
+
+func TestFakeRetentionPolicy(t *testing.T) {
+	mgr, _ := newFakeSecretManager(t, gcpsecretsmanager.WithRetentionPolicy(gcpsecretsmanager.RetentionPolicy{KeepVersions: 2}))
+	versions := mgr.(gcpsecretsmanager.VersionInterface)
+
+	for _, v := range []string{"v1", "v2", "v3", "v4"} {
+		require.NoError(t, mgr.SetSecret(testProject, "rotated", &secretstore.SecretValue{Value: v}))
+	}
+	list, err := versions.ListSecretVersions(testProject, "rotated")
+	require.NoError(t, err)
+	var states []string
+	for _, v := range list {
+		states = append(states, v.Version+"="+v.State)
+	}
+	assert.Equal(t, []string{"4=ENABLED", "3=ENABLED", "2=DISABLED", "1=DISABLED"}, states)
+
+	require.NoError(t, versions.EnableSecretVersion(testProject, "rotated", "1"))
+	require.NoError(t, versions.PruneSecretVersions(testProject, "rotated", gcpsecretsmanager.RetentionPolicy{KeepVersions: 1, Destroy: true}))
+	list, err = versions.ListSecretVersions(testProject, "rotated")
+	require.NoError(t, err)
+	states = nil
+	for _, v := range list {
+		states = append(states, v.Version+"="+v.State)
+	}
+	assert.Equal(t, []string{"4=ENABLED", "3=DESTROYED", "2=DESTROYED", "1=DESTROYED"}, states)
+
+	value, err := mgr.GetSecret(testProject, "rotated", "")
+	require.NoError(t, err)
+	assert.Equal(t, "v4", value)
+}
+
+func TestFakeRetentionPolicyKeepsAtLeastOneVersion(t *testing.T) {
+	mgr, _ := newFakeSecretManager(t, gcpsecretsmanager.WithRetentionPolicy(gcpsecretsmanager.RetentionPolicy{}))
+
+	for _, v := range []string{"v1", "v2"} {
+		require.NoError(t, mgr.SetSecret(testProject, "rotated", &secretstore.SecretValue{Value: v}))
+	}
+	list, err := mgr.(gcpsecretsmanager.VersionInterface).ListSecretVersions(testProject, "rotated")
+	require.NoError(t, err)
+	var states []string
+	for _, v := range list {
+		states = append(states, v.Version+"="+v.State)
+	}
+	assert.Equal(t, []string{"2=ENABLED", "1=DISABLED"}, states)
+}
+
+func TestFakeLifecycle(t *testing.T) {
+	topic := "projects/test-project/topics/secrets"
+	mgr, server := newFakeSecretManager(t, gcpsecretsmanager.WithNotificationTopics(topic))
+	metadata := mgr.(secretstore.MetadataInterface)
+
+	next := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := mgr.SetSecret(testProject, "expiring", &secretstore.SecretValue{
+		Value: "v1",
+		Lifecycle: &secretstore.SecretLifecycle{
+			TTL:              time.Hour,
+			RotationPeriod:   24 * time.Hour,
+			NextRotationTime: next,
+		},
+	})
+	require.NoError(t, err)
+
+	secret := server.Secret("projects/test-project/secrets/expiring")
+	require.Len(t, secret.Topics, 1)
+	assert.Equal(t, topic, secret.Topics[0].Name)
+	m, err := metadata.GetSecretMetadata(testProject, "expiring")
+	require.NoError(t, err)
+	require.NotNil(t, m.Lifecycle)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), m.Lifecycle.ExpireTime, time.Minute)
+	assert.Equal(t, 24*time.Hour, m.Lifecycle.RotationPeriod)
+	assert.True(t, next.Equal(m.Lifecycle.NextRotationTime))
+
+	expireTime := time.Date(2031, 6, 1, 0, 0, 0, 0, time.UTC)
+	err = mgr.SetSecret(testProject, "expiring", &secretstore.SecretValue{
+		Value:     "v2",
+		Lifecycle: &secretstore.SecretLifecycle{ExpireTime: expireTime, RotationPeriod: 48 * time.Hour},
+	})
+	require.NoError(t, err)
+	m, err = metadata.GetSecretMetadata(testProject, "expiring")
+	require.NoError(t, err)
+	assert.True(t, expireTime.Equal(m.Lifecycle.ExpireTime))
+	assert.Equal(t, 48*time.Hour, m.Lifecycle.RotationPeriod)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), m.Lifecycle.NextRotationTime, time.Minute)
+
+	err = mgr.SetSecret(testProject, "expiring", &secretstore.SecretValue{
+		Value:     "v3",
+		Lifecycle: &secretstore.SecretLifecycle{ExpireTime: expireTime, TTL: time.Hour},
+	})
+	assert.Error(t, err)
+}
+
+func TestFakeRotationRequiresNotificationTopics(t *testing.T) {
+	mgr, server := newFakeSecretManager(t)
+
+	err := mgr.SetSecret(testProject, "rotated", &secretstore.SecretValue{
+		Value:     "v1",
+		Lifecycle: &secretstore.SecretLifecycle{RotationPeriod: 24 * time.Hour},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WithNotificationTopics")
+	assert.Nil(t, server.Secret("projects/test-project/secrets/rotated"))
+
+	require.NoError(t, mgr.SetSecret(testProject, "rotated", &secretstore.SecretValue{Value: "v1"}))
+	err = mgr.SetSecret(testProject, "rotated", &secretstore.SecretValue{
+		Value:     "v2",
+		Lifecycle: &secretstore.SecretLifecycle{RotationPeriod: 24 * time.Hour},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WithNotificationTopics")
+}
+
+func TestFakeMergeReappliedAfterConcurrentWrite(t *testing.T) {
+	mgr, server := newFakeSecretManager(t)
+	other := gcpsecretsmanager.NewGcpSecretsManager(google.Credentials{},
+		gcpsecretsmanager.WithEndpoint(server.Addr()), gcpsecretsmanager.WithInsecure())
+	defer other.(io.Closer).Close()
+
+	require.NoError(t, mgr.SetSecret(testProject, "shared", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"a": "1"},
+	}))
+
+	concurrentWrites := 1
+	server.BeforeAddSecretVersion = func(*secretmanagerpb.AddSecretVersionRequest) {
+		if concurrentWrites == 0 {
+			return
+		}
+		concurrentWrites--
+		require.NoError(t, other.SetSecret(testProject, "shared", &secretstore.SecretValue{
+			PropertyValues: map[string]string{"b": "2"},
+		}))
+	}
+	require.NoError(t, mgr.SetSecret(testProject, "shared", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"c": "3"},
+	}))
+
+	for key, expected := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		value, err := mgr.GetSecret(testProject, "shared", key)
+		require.NoError(t, err)
+		assert.Equal(t, expected, value, key)
+	}
+}
+
+func TestFakeMergeConflict(t *testing.T) {
+	mgr, server := newFakeSecretManager(t, gcpsecretsmanager.WithConflictRetries(0))
+	other := gcpsecretsmanager.NewGcpSecretsManager(google.Credentials{},
+		gcpsecretsmanager.WithEndpoint(server.Addr()), gcpsecretsmanager.WithInsecure())
+	defer other.(io.Closer).Close()
+
+	require.NoError(t, mgr.SetSecret(testProject, "shared", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"a": "1"},
+	}))
+	concurrentWrites := 1
+	server.BeforeAddSecretVersion = func(*secretmanagerpb.AddSecretVersionRequest) {
+		if concurrentWrites == 0 {
+			return
+		}
+		concurrentWrites--
+		require.NoError(t, other.SetSecret(testProject, "shared", &secretstore.SecretValue{Value: `{"b":"2"}`}))
+	}
+	err := mgr.SetSecret(testProject, "shared", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"c": "3"},
+	})
+	require.Error(t, err)
+	assert.True(t, secretstore.IsConflict(err))
+
+	versions := server.Versions("projects/test-project/secrets/shared")
+	require.Len(t, versions, 4)
+	assert.Equal(t, secretmanagerpb.SecretVersion_DISABLED, versions[2].State, "the merged version should be withdrawn")
+	value, err := mgr.GetSecret(testProject, "shared", "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":"2"}`, value)
+}
+
+func TestFakeMergeIgnoresDisabledConcurrentVersions(t *testing.T) {
+	mgr, server := newFakeSecretManager(t, gcpsecretsmanager.WithConflictRetries(0))
+	other := gcpsecretsmanager.NewGcpSecretsManager(google.Credentials{},
+		gcpsecretsmanager.WithEndpoint(server.Addr()), gcpsecretsmanager.WithInsecure())
+	defer other.(io.Closer).Close()
+
+	require.NoError(t, mgr.SetSecret(testProject, "shared", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"a": "1"},
+	}))
+	concurrentWrites := 1
+	server.BeforeAddSecretVersion = func(*secretmanagerpb.AddSecretVersionRequest) {
+		if concurrentWrites == 0 {
+			return
+		}
+		concurrentWrites--
+		require.NoError(t, other.SetSecret(testProject, "shared", &secretstore.SecretValue{Value: `{"b":"2"}`}))
+		require.NoError(t, other.(gcpsecretsmanager.VersionInterface).DisableSecretVersion(testProject, "shared", "2"))
+	}
+	require.NoError(t, mgr.SetSecret(testProject, "shared", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"c": "3"},
+	}))
+
+	value, err := mgr.GetSecret(testProject, "shared", "c")
+	require.NoError(t, err)
+	assert.Equal(t, "3", value)
+}
+
+func TestFakeCreateSecret(t *testing.T) {
+	mgr, _ := newFakeSecretManager(t)
+	creator, ok := mgr.(secretstore.CreateInterface)
+	require.True(t, ok)
+
+	require.NoError(t, creator.CreateSecret(testProject, "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "admin"}}))
+	err := creator.CreateSecret(testProject, "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "root"}})
+	assert.True(t, secretstore.IsConflict(err))
+
+	password, created, err := generator.SetIfAbsent(mgr, testProject, "db", "password", func() (string, error) {
+		return "generated", nil
+	})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "generated", password)
+	value, err := mgr.GetSecret(testProject, "db", "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"username":"admin","password":"generated"}`, value)
+}
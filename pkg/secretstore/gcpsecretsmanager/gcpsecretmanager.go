@@ -4,72 +4,162 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/iam/gcpiam"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
-	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/credentials/oauth"
+	"google.golang.org/grpc/status"
 )
 
-func NewGcpSecretsManager(creds google.Credentials) secretstore.Interface {
-	return &gcpSecretsManager{creds}
+func NewGcpSecretsManager(creds google.Credentials, opts ...Option) secretstore.Interface {
+	g := &gcpSecretsManager{creds: creds}
+	for _, o := range opts {
+		o(g)
+	}
+	return g
 }
 
 type gcpSecretsManager struct {
-	creds google.Credentials
+	creds             google.Credentials
+	clientLock        sync.Mutex
+	client            *secretmanager.Client
+	notifications     NotificationSubscriber
+	watchLock         sync.Mutex
+	watchers          map[string]map[*notificationWatcher]bool
+	receiver          *notificationReceiver
+	replication       *ReplicationPolicy
+	secretReplication map[string]*ReplicationPolicy
+	driftHandler      DriftHandler
+	retention         *RetentionPolicy
+	topics            []string
+	conflictRetries   *int
+	endpoint          string
+	insecure          bool
+	clientOptions     []option.ClientOption
+}
+
+// Option configures a GCP Secret Manager secret manager
+type Option func(*gcpSecretsManager)
+
+// WithEndpoint overrides the Secret Manager endpoint, e.g. to use a regional endpoint or a local emulator
+func WithEndpoint(endpoint string) Option {
+	return func(g *gcpSecretsManager) {
+		g.endpoint = endpoint
+	}
+}
+
+// WithInsecure connects to the endpoint without TLS or authentication. It is only intended for local emulators.
+func WithInsecure() Option {
+	return func(g *gcpSecretsManager) {
+		g.insecure = true
+	}
+}
+
+// WithClientOptions adds options used to create the Secret Manager client, such as option.WithCredentialsJSON or
+// option.WithGRPCConn. They are applied after, and so take precedence over, the options derived from the credentials.
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(g *gcpSecretsManager) {
+		g.clientOptions = append(g.clientOptions, opts...)
+	}
 }
 
 func (g *gcpSecretsManager) SetSecret(projectID, secretName string, secretValue *secretstore.SecretValue) error {
 
-	client, closer, err := getSecretOpsClient()
+	client, err := g.getClient()
 	if err != nil {
 		return errors.Wrapf(err, "error setting GCP Secrets Manager secret %s in project %s", secretName, projectID)
 	}
-	defer closer()
 
-	var existingSecretProps map[string]string
+	merge := false
 	secret, err := getSecret(client, projectID, secretName)
 	if err != nil {
-		secret, err = createSecret(client, projectID, secretName)
+		var template *secretmanagerpb.Secret
+		template, err = g.newSecret(secretName, secretValue)
 		if err != nil {
-			return errors.Wrapf(err, "error creating new secret %s in GCP secret manager project %s", secretName, projectID)
+			return err
 		}
-	} else if secretValue.Value == "" && secretValue.PropertyValues != nil {
-		sv, err := getSecretValue(client, projectID, secretName)
+		secret, err = createSecret(client, projectID, secretName, template)
 		if err != nil {
-			return errors.Wrapf(err, "error getting GCP secrets manager secret value for secret name %s in project %s", secretName, projectID)
+			return errors.Wrapf(err, "error creating new secret %s in GCP secret manager project %s", secretName, projectID)
 		}
-		existingSecretProps, err = getSecretPropertyMap(sv)
+	} else {
+		g.checkReplicationDrift(projectID, secretName, secret)
+		secret, err = g.updateSecretMetadata(client, secret, secretValue)
 		if err != nil {
-			return errors.Wrap(err, "error getting secret property map")
+			return errors.Wrapf(err, "error updating secret %s in GCP secret manager project %s", secretName, projectID)
 		}
+		merge = secretValue.Value == "" && secretValue.BinaryValue == nil && secretValue.PropertyValues != nil
 	}
 
-	req := &secretmanagerpb.AddSecretVersionRequest{
-		Parent: secret.Name,
-		Payload: &secretmanagerpb.SecretPayload{
-			Data: []byte(secretValue.MergeExistingSecret(existingSecretProps)),
-		},
+	if merge {
+		err = g.addMergedSecretVersion(client, projectID, secretName, secretValue)
+	} else {
+		_, err = addSecretVersion(client, secret.Name, []byte(secretValue.ToString()))
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to set secret %s in GCP secret manager project %s", secretName, projectID)
+	}
+	if g.retention != nil {
+		return g.PruneSecretVersions(projectID, secretName, *g.retention)
+	}
+	return nil
+}
+
+// CreateSecret creates a secret with the secret value as its first version, returning a secretstore.ConflictError if
+// the secret already exists. A secret is created before its first version is added, so until then it can exist
+// without a value.
+func (g *gcpSecretsManager) CreateSecret(projectID, secretName string, secretValue *secretstore.SecretValue) error {
+	client, err := g.getClient()
+	if err != nil {
+		return errors.Wrapf(err, "error creating GCP Secrets Manager secret %s in project %s", secretName, projectID)
+	}
+	template, err := g.newSecret(secretName, secretValue)
+	if err != nil {
+		return err
 	}
-	_, err = client.AddSecretVersion(context.TODO(), req)
+	secret, err := createSecret(client, projectID, secretName, template)
+	if status.Code(errors.Cause(err)) == codes.AlreadyExists {
+		return &secretstore.ConflictError{Location: projectID, SecretName: secretName, Err: errors.Cause(err)}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error creating new secret %s in GCP secret manager project %s", secretName, projectID)
+	}
+	_, err = addSecretVersion(client, secret.Name, []byte(secretValue.ToString()))
 	if err != nil {
 		return errors.Wrapf(err, "unable to set secret %s in GCP secret manager project %s", secretName, projectID)
 	}
 	return nil
 }
 
+// newSecret returns the secret to create for a secret value, with its replication, labels, annotations and lifecycle
+func (g *gcpSecretsManager) newSecret(secretName string, secretValue *secretstore.SecretValue) (*secretmanagerpb.Secret, error) {
+	template := &secretmanagerpb.Secret{
+		Replication: g.replicationPolicy(secretName).replication(),
+		Labels:      secretValue.Labels,
+		Annotations: secretValue.Annotations,
+	}
+	err := g.setLifecycle(template, secretValue.Lifecycle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid lifecycle for secret %s", secretName)
+	}
+	return template, nil
+}
+
 func (g *gcpSecretsManager) GetSecret(projectID, secretName, secretKey string) (string, error) {
-	client, closer, err := getSecretOpsClient()
+	client, err := g.getClient()
 	if err != nil {
-		return "", errors.Wrap(err, "error creating GCP secret manager client")
+		return "", err
 	}
-	defer closer()
 
 	secret, err := getSecretValue(client, projectID, secretName)
 	if err != nil {
@@ -104,35 +194,77 @@ func getSecretProperty(v *secretmanagerpb.SecretPayload, propertyName string) (s
 	return m[propertyName], nil
 }
 
-func getSecretOpsClient() (*secretmanager.Client, func(), error) {
-	creds, err := gcpiam.DefaultCredentials()
+// getClient returns the secret manager's client, dialing it on first use. The client is shared by every operation
+// until Close is called.
+func (g *gcpSecretsManager) getClient() (*secretmanager.Client, error) {
+	g.clientLock.Lock()
+	defer g.clientLock.Unlock()
+	if g.client != nil {
+		return g.client, nil
+	}
+
+	opts, err := g.dialOptions()
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "error getting GCP default credentials")
+		return nil, err
 	}
-	client, err := secretmanager.NewClient(context.TODO(),
-		option.WithGRPCDialOption(
-			grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, "")),
-		),
-		option.WithTokenSource(oauth.TokenSource{TokenSource: creds.TokenSource}),
-	)
+	client, err := secretmanager.NewClient(context.TODO(), opts...)
 	if err != nil {
-		return nil, nil, err
+		return nil, errors.Wrap(err, "error creating GCP secret manager client")
+	}
+	g.client = client
+	return client, nil
+}
+
+// dialOptions returns the options used to create the client
+func (g *gcpSecretsManager) dialOptions() ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if g.endpoint != "" {
+		opts = append(opts, option.WithEndpoint(g.endpoint))
+	}
+	if g.insecure {
+		opts = append(opts,
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			option.WithoutAuthentication(),
+		)
+		return append(opts, g.clientOptions...), nil
 	}
 
-	return client, func() { _ = client.Close() }, nil
+	tokenSource := g.creds.TokenSource
+	if tokenSource == nil && len(g.clientOptions) == 0 {
+		creds, err := gcpiam.DefaultCredentials()
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting GCP default credentials")
+		}
+		tokenSource = creds.TokenSource
+	}
+	opts = append(opts, option.WithGRPCDialOption(
+		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, "")),
+	))
+	if tokenSource != nil {
+		opts = append(opts, option.WithTokenSource(oauth.TokenSource{TokenSource: tokenSource}))
+	}
+	return append(opts, g.clientOptions...), nil
 }
 
-func createSecret(client *secretmanager.Client, projectID, secretName string) (*secretmanagerpb.Secret, error) {
+// Close stops the watchers using notifications and closes the connection to GCP Secret Manager. A new connection is
+// dialed if the secret manager is used again.
+func (g *gcpSecretsManager) Close() error {
+	g.stopWatchers()
+	g.clientLock.Lock()
+	defer g.clientLock.Unlock()
+	if g.client == nil {
+		return nil
+	}
+	err := g.client.Close()
+	g.client = nil
+	return err
+}
+
+func createSecret(client *secretmanager.Client, projectID, secretName string, secret *secretmanagerpb.Secret) (*secretmanagerpb.Secret, error) {
 	req := &secretmanagerpb.CreateSecretRequest{
 		Parent:   fmt.Sprintf("projects/%s", projectID),
 		SecretId: secretName,
-		Secret: &secretmanagerpb.Secret{
-			Replication: &secretmanagerpb.Replication{
-				Replication: &secretmanagerpb.Replication_Automatic_{
-					Automatic: &secretmanagerpb.Replication_Automatic{},
-				},
-			},
-		},
+		Secret:   secret,
 	}
 	secret, err := client.CreateSecret(context.TODO(), req)
 	if err != nil {
@@ -147,7 +279,9 @@ func getSecret(client *secretmanager.Client, projectID, secretName string) (*sec
 		Name: fmt.Sprintf("projects/%s/secrets/%s", projectID, secretName),
 	}
 	secret, err := client.GetSecret(context.TODO(), req)
-
+	if isNotFound(err) {
+		return nil, &secretstore.NotFoundError{Location: projectID, SecretName: secretName, Err: err}
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting secret %s for GCP secrets manager project %s", secretName, projectID)
 	}
@@ -160,8 +294,16 @@ func getSecretValue(client *secretmanager.Client, projectID, secretName string)
 		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, secretName),
 	}
 	secret, err := client.AccessSecretVersion(context.TODO(), req)
+	if isNotFound(err) {
+		return nil, &secretstore.NotFoundError{Location: projectID, SecretName: secretName, Err: err}
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting secret value for secret %s for GCP secrets manager project %s", secretName, projectID)
 	}
 	return secret.Payload, nil
 }
+
+// isNotFound reports whether a Secret Manager call failed because the secret or version doesn't exist
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
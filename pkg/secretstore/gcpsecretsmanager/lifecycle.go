@@ -0,0 +1,161 @@
+package gcpsecretsmanager
+
+import (
+	"sort"
+	"time"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// WithNotificationTopics sets the Pub/Sub topics, in the form projects/*/topics/*, that Secret Manager publishes
+// changes to secrets to. Secret Manager requires at least one topic for secrets with a rotation period.
+func WithNotificationTopics(topics ...string) Option {
+	return func(g *gcpSecretsManager) {
+		g.topics = append(g.topics, topics...)
+	}
+}
+
+// errNoRotationTopics is returned for a rotation period without notification topics, which Secret Manager rejects
+var errNoRotationTopics = errors.New("a secret with a rotation period requires notification topics, set them with WithNotificationTopics")
+
+// setLifecycle sets the expiry, rotation and topics of a secret being created
+func (g *gcpSecretsManager) setLifecycle(secret *secretmanagerpb.Secret, lifecycle *secretstore.SecretLifecycle) error {
+	secret.Topics = g.notificationTopics()
+	if lifecycle == nil {
+		return nil
+	}
+	if err := validateLifecycle(lifecycle); err != nil {
+		return err
+	}
+	if lifecycle.RotationPeriod != 0 && len(secret.Topics) == 0 {
+		return errNoRotationTopics
+	}
+	setExpiration(secret, lifecycle)
+	secret.Rotation = rotation(lifecycle, time.Now())
+	return nil
+}
+
+// updateLifecycle sets the expiry, rotation and topics of update where they differ from those of an existing secret,
+// returning the update mask paths of the changed fields
+func (g *gcpSecretsManager) updateLifecycle(existing, update *secretmanagerpb.Secret, lifecycle *secretstore.SecretLifecycle) ([]string, error) {
+	var paths []string
+	if len(g.topics) > 0 && !sameTopics(existing.Topics, g.topics) {
+		update.Topics = g.notificationTopics()
+		paths = append(paths, "topics")
+	}
+	if lifecycle == nil {
+		return paths, nil
+	}
+	if err := validateLifecycle(lifecycle); err != nil {
+		return nil, err
+	}
+	if lifecycle.RotationPeriod != 0 && len(g.topics) == 0 && len(existing.Topics) == 0 {
+		return nil, errNoRotationTopics
+	}
+
+	switch {
+	case lifecycle.TTL != 0:
+		// a TTL restarts the countdown to expiry on every write
+		setExpiration(update, lifecycle)
+		paths = append(paths, "ttl")
+	case !lifecycle.ExpireTime.IsZero() && !lifecycle.ExpireTime.Equal(existing.GetExpireTime().AsTime()):
+		setExpiration(update, lifecycle)
+		paths = append(paths, "expire_time")
+	}
+
+	if lifecycle.RotationPeriod != 0 {
+		current := existing.GetRotation()
+		periodChanged := current.GetRotationPeriod().AsDuration() != lifecycle.RotationPeriod
+		nextChanged := !lifecycle.NextRotationTime.IsZero() && !lifecycle.NextRotationTime.Equal(current.GetNextRotationTime().AsTime())
+		if current == nil || periodChanged || nextChanged {
+			update.Rotation = rotation(lifecycle, time.Now())
+			paths = append(paths, "rotation")
+		}
+	}
+	return paths, nil
+}
+
+// getLifecycle returns the expiry and rotation of a secret, or nil if it has neither
+func getLifecycle(secret *secretmanagerpb.Secret) *secretstore.SecretLifecycle {
+	if secret.GetExpireTime() == nil && secret.GetRotation() == nil {
+		return nil
+	}
+	lifecycle := &secretstore.SecretLifecycle{}
+	if t := secret.GetExpireTime(); t != nil {
+		lifecycle.ExpireTime = t.AsTime()
+	}
+	if r := secret.GetRotation(); r != nil {
+		if r.RotationPeriod != nil {
+			lifecycle.RotationPeriod = r.RotationPeriod.AsDuration()
+		}
+		if r.NextRotationTime != nil {
+			lifecycle.NextRotationTime = r.NextRotationTime.AsTime()
+		}
+	}
+	return lifecycle
+}
+
+func validateLifecycle(lifecycle *secretstore.SecretLifecycle) error {
+	if !lifecycle.ExpireTime.IsZero() && lifecycle.TTL != 0 {
+		return errors.New("only one of the expire time and TTL of a secret may be set")
+	}
+	if lifecycle.TTL < 0 || lifecycle.RotationPeriod < 0 {
+		return errors.New("the TTL and rotation period of a secret must not be negative")
+	}
+	return nil
+}
+
+// setExpiration sets the TTL or expire time of a secret
+func setExpiration(secret *secretmanagerpb.Secret, lifecycle *secretstore.SecretLifecycle) {
+	switch {
+	case lifecycle.TTL != 0:
+		secret.Expiration = &secretmanagerpb.Secret_Ttl{Ttl: durationpb.New(lifecycle.TTL)}
+	case !lifecycle.ExpireTime.IsZero():
+		secret.Expiration = &secretmanagerpb.Secret_ExpireTime{ExpireTime: timestamppb.New(lifecycle.ExpireTime)}
+	}
+}
+
+func rotation(lifecycle *secretstore.SecretLifecycle, now time.Time) *secretmanagerpb.Rotation {
+	if lifecycle.RotationPeriod == 0 {
+		return nil
+	}
+	next := lifecycle.NextRotationTime
+	if next.IsZero() {
+		next = now.Add(lifecycle.RotationPeriod)
+	}
+	return &secretmanagerpb.Rotation{
+		NextRotationTime: timestamppb.New(next),
+		RotationPeriod:   durationpb.New(lifecycle.RotationPeriod),
+	}
+}
+
+func (g *gcpSecretsManager) notificationTopics() []*secretmanagerpb.Topic {
+	var topics []*secretmanagerpb.Topic
+	for _, name := range g.topics {
+		topics = append(topics, &secretmanagerpb.Topic{Name: name})
+	}
+	return topics
+}
+
+func sameTopics(topics []*secretmanagerpb.Topic, names []string) bool {
+	if len(topics) != len(names) {
+		return false
+	}
+	actual := make([]string, 0, len(topics))
+	for _, t := range topics {
+		actual = append(actual, t.Name)
+	}
+	expected := append([]string(nil), names...)
+	sort.Strings(actual)
+	sort.Strings(expected)
+	for i := range actual {
+		if actual[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
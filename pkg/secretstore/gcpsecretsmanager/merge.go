@@ -0,0 +1,158 @@
+package gcpsecretsmanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// DefaultConflictRetries is the number of times a property merge is reapplied after a concurrent modification
+const DefaultConflictRetries = 3
+
+// WithConflictRetries sets the number of times a property merge is reapplied after a concurrent modification before
+// SetSecret returns a secretstore.ConflictError. Zero returns the error on the first conflict.
+func WithConflictRetries(retries int) Option {
+	return func(g *gcpSecretsManager) {
+		g.conflictRetries = &retries
+	}
+}
+
+// addMergedSecretVersion merges the property values into those of the latest version of a secret and adds the
+// result as a new version.
+//
+// Adding a version can't be made conditional, and a secret's etag only changes when its metadata does, so concurrent
+// writers are detected from version numbers instead, which increase by one with every version added. Any version
+// between the one that was merged into and the one that was added was written concurrently and is missing from the
+// added version, so the merge is reapplied on top of the newest of them that is enabled. When the retries run out the
+// versions added by the merge are disabled before a secretstore.ConflictError is returned.
+func (g *gcpSecretsManager) addMergedSecretVersion(client *secretmanager.Client, projectID, secretName string, secretValue *secretstore.SecretValue) error {
+	retries := DefaultConflictRetries
+	if g.conflictRetries != nil {
+		retries = *g.conflictRetries
+	}
+
+	parent := fmt.Sprintf("projects/%s/secrets/%s", projectID, secretName)
+	base := "latest"
+	own := map[int]bool{}
+	for attempt := 1; ; attempt++ {
+		existing, err := accessSecretVersion(client, projectID, secretName, base)
+		if err != nil {
+			return errors.Wrapf(err, "error getting GCP secrets manager secret value for secret name %s in project %s", secretName, projectID)
+		}
+		existingSecretProps, err := getSecretPropertyMap(existing.Payload)
+		if err != nil {
+			return errors.Wrap(err, "error getting secret property map")
+		}
+		baseNumber, err := strconv.Atoi(path.Base(existing.Name))
+		if err != nil {
+			return errors.Wrapf(err, "unexpected version name %s", existing.Name)
+		}
+
+		added, err := addSecretVersion(client, parent, []byte(secretValue.MergeExistingSecret(existingSecretProps)))
+		if err != nil {
+			return err
+		}
+		addedNumber, err := strconv.Atoi(path.Base(added.Name))
+		if err != nil {
+			return errors.Wrapf(err, "unexpected version name %s", added.Name)
+		}
+		own[addedNumber] = true
+
+		if !mayHaveConcurrentVersions(baseNumber, addedNumber, own) {
+			return nil
+		}
+		versions, err := listSecretVersions(client, projectID, secretName)
+		if err != nil {
+			return err
+		}
+		concurrent := newestConcurrentVersion(versions, baseNumber, addedNumber, own)
+		if concurrent == 0 {
+			return nil
+		}
+		if attempt > retries {
+			err = withdrawMergedVersions(client, projectID, secretName, versions, own, concurrent)
+			if err != nil {
+				return errors.Wrapf(err, "error withdrawing the merged versions of secret %s in project %s after a conflict", secretName, projectID)
+			}
+			return &secretstore.ConflictError{Location: projectID, SecretName: secretName, Attempts: attempt}
+		}
+		base = strconv.Itoa(concurrent)
+	}
+}
+
+// mayHaveConcurrentVersions returns whether any version between the base and added versions was not added by the merge
+func mayHaveConcurrentVersions(baseNumber, addedNumber int, own map[int]bool) bool {
+	for n := addedNumber - 1; n > baseNumber; n-- {
+		if !own[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// newestConcurrentVersion returns the number of the newest enabled version between the base and added versions that
+// was not added by the merge, or 0 if there is none. Disabled and destroyed versions can't be read, so they are not
+// merged.
+func newestConcurrentVersion(versions []*secretmanagerpb.SecretVersion, baseNumber, addedNumber int, own map[int]bool) int {
+	for _, v := range versions {
+		n := versionNumber(v)
+		if n > baseNumber && n < addedNumber && !own[n] && v.State == secretmanagerpb.SecretVersion_ENABLED {
+			return n
+		}
+	}
+	return 0
+}
+
+// withdrawMergedVersions disables the versions added by a merge that gave up on a conflict, none of which contain the
+// concurrent version. If one of them is the newest version of the secret, the concurrent version is added again first
+// so that the latest alias keeps resolving to a readable version with its values.
+func withdrawMergedVersions(client *secretmanager.Client, projectID, secretName string, versions []*secretmanagerpb.SecretVersion, own map[int]bool, concurrent int) error {
+	if len(versions) > 0 && own[versionNumber(versions[0])] {
+		existing, err := accessSecretVersion(client, projectID, secretName, strconv.Itoa(concurrent))
+		if err != nil {
+			return err
+		}
+		_, err = addSecretVersion(client, fmt.Sprintf("projects/%s/secrets/%s", projectID, secretName), existing.Payload.Data)
+		if err != nil {
+			return err
+		}
+	}
+	for n := range own {
+		_, err := client.DisableSecretVersion(context.TODO(), &secretmanagerpb.DisableSecretVersionRequest{
+			Name: versionName(projectID, secretName, strconv.Itoa(n)),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error disabling version %d of secret %s", n, secretName)
+		}
+	}
+	return nil
+}
+
+func addSecretVersion(client *secretmanager.Client, secretName string, data []byte) (*secretmanagerpb.SecretVersion, error) {
+	req := &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  secretName,
+		Payload: &secretmanagerpb.SecretPayload{Data: data},
+	}
+	version, err := client.AddSecretVersion(context.TODO(), req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error adding version to secret %s", secretName)
+	}
+	return version, nil
+}
+
+func accessSecretVersion(client *secretmanager.Client, projectID, secretName, version string) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	req := &secretmanagerpb.AccessSecretVersionRequest{
+		Name: versionName(projectID, secretName, version),
+	}
+	resp, err := client.AccessSecretVersion(context.TODO(), req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting version %s of secret %s for GCP secrets manager project %s", version, secretName, projectID)
+	}
+	return resp, nil
+}
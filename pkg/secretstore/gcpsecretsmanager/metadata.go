@@ -0,0 +1,90 @@
+package gcpsecretsmanager
+
+import (
+	"context"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// GetSecretMetadata returns the labels, annotations, expiry and rotation of a secret and the number of its latest
+// version
+func (g *gcpSecretsManager) GetSecretMetadata(projectID, secretName string) (*secretstore.SecretMetadata, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := getSecret(client, projectID, secretName)
+	if err != nil {
+		return nil, err
+	}
+	version, err := g.latestVersion(projectID, secretName)
+	if err != nil && !secretstore.IsNotFound(err) {
+		return nil, err
+	}
+	return &secretstore.SecretMetadata{
+		Labels:      secret.Labels,
+		Annotations: secret.Annotations,
+		Version:     version,
+		CreateTime:  secret.CreateTime.AsTime(),
+		Lifecycle:   getLifecycle(secret),
+	}, nil
+}
+
+// updateSecretMetadata merges the labels and annotations of the secret value into those of an existing secret, removes
+// those it asks to remove and applies its lifecycle, updating the secret only if any of them have changed
+func (g *gcpSecretsManager) updateSecretMetadata(client *secretmanager.Client, secret *secretmanagerpb.Secret, secretValue *secretstore.SecretValue) (*secretmanagerpb.Secret, error) {
+	update := &secretmanagerpb.Secret{Name: secret.Name}
+	var paths []string
+	if labels, changed := mergeMetadata(secret.Labels, secretValue.Labels, secretValue.RemoveLabels); changed {
+		update.Labels = labels
+		paths = append(paths, "labels")
+	}
+	if annotations, changed := mergeMetadata(secret.Annotations, secretValue.Annotations, secretValue.RemoveAnnotations); changed {
+		update.Annotations = annotations
+		paths = append(paths, "annotations")
+	}
+	lifecyclePaths, err := g.updateLifecycle(secret, update, secretValue.Lifecycle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid lifecycle for secret %s", secret.Name)
+	}
+	paths = append(paths, lifecyclePaths...)
+	if len(paths) == 0 {
+		return secret, nil
+	}
+	updated, err := client.UpdateSecret(context.TODO(), &secretmanagerpb.UpdateSecretRequest{
+		Secret:     update,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error updating metadata of secret %s", secret.Name)
+	}
+	return updated, nil
+}
+
+// mergeMetadata returns existing without the keys in remove, overlaid with values, and whether that differs from
+// existing
+func mergeMetadata(existing, values map[string]string, remove []string) (map[string]string, bool) {
+	merged := map[string]string{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	changed := false
+	for _, k := range remove {
+		if _, ok := merged[k]; ok {
+			delete(merged, k)
+			changed = true
+		}
+	}
+	for k, v := range values {
+		if current, ok := merged[k]; !ok || current != v {
+			changed = true
+		}
+		merged[k] = v
+	}
+	return merged, changed
+}
@@ -0,0 +1,21 @@
+package gcpsecretsmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeMetadata(t *testing.T) {
+	merged, changed := mergeMetadata(map[string]string{"a": "1"}, map[string]string{"a": "1"}, nil)
+	assert.False(t, changed)
+	assert.Equal(t, map[string]string{"a": "1"}, merged)
+
+	merged, changed = mergeMetadata(map[string]string{"a": "1"}, map[string]string{"b": "2"}, nil)
+	assert.True(t, changed)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, merged)
+
+	merged, changed = mergeMetadata(map[string]string{"a": "1", "b": "2"}, nil, []string{"b", "c"})
+	assert.True(t, changed)
+	assert.Equal(t, map[string]string{"a": "1"}, merged)
+}
@@ -0,0 +1,144 @@
+package gcpsecretsmanager
+
+import (
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReplicationPolicy controls where the replicas of newly created secrets are stored and how they are encrypted.
+// Replication can't be changed once a secret exists so it only applies on creation; existing secrets that differ
+// are reported to the DriftHandler.
+type ReplicationPolicy struct {
+	// Locations pins the replicas to these Cloud locations, e.g. europe-west1. Automatic replication is used if empty.
+	Locations []string
+	// KMSKeyName is the Cloud KMS key used to encrypt the secret, for automatic replication or every replica
+	KMSKeyName string
+	// ReplicaKMSKeyNames overrides KMSKeyName for individual locations, as user-managed replicas need a key in
+	// their own location
+	ReplicaKMSKeyNames map[string]string
+}
+
+// ReplicationDrift describes an existing secret whose replication differs from its policy
+type ReplicationDrift struct {
+	ProjectID  string
+	SecretName string
+	Expected   *secretmanagerpb.Replication
+	Actual     *secretmanagerpb.Replication
+}
+
+func (d *ReplicationDrift) String() string {
+	return fmt.Sprintf("replication of secret %s in project %s is {%s} but policy expects {%s}",
+		d.SecretName, d.ProjectID, prototext.Format(d.Actual), prototext.Format(d.Expected))
+}
+
+// DriftHandler is called when an existing secret no longer matches the configuration it would be created with
+type DriftHandler func(drift *ReplicationDrift)
+
+// WithReplicationPolicy sets the replication policy of every secret created without a secret specific policy
+func WithReplicationPolicy(policy ReplicationPolicy) Option {
+	return func(g *gcpSecretsManager) {
+		g.replication = &policy
+	}
+}
+
+// WithSecretReplicationPolicy sets the replication policy of a single secret, overriding WithReplicationPolicy
+func WithSecretReplicationPolicy(secretName string, policy ReplicationPolicy) Option {
+	return func(g *gcpSecretsManager) {
+		if g.secretReplication == nil {
+			g.secretReplication = map[string]*ReplicationPolicy{}
+		}
+		g.secretReplication[secretName] = &policy
+	}
+}
+
+// WithDriftHandler replaces the default handler, which logs a warning, for existing secrets whose replication differs
+// from their policy
+func WithDriftHandler(handler DriftHandler) Option {
+	return func(g *gcpSecretsManager) {
+		g.driftHandler = handler
+	}
+}
+
+func logDrift(drift *ReplicationDrift) {
+	log.Logger().Warnf("%s", drift)
+}
+
+// replicationPolicy returns the policy for the secret, or nil if none has been configured
+func (g *gcpSecretsManager) replicationPolicy(secretName string) *ReplicationPolicy {
+	if p, ok := g.secretReplication[secretName]; ok {
+		return p
+	}
+	return g.replication
+}
+
+// checkReplicationDrift reports an existing secret whose replication differs from its configured policy
+func (g *gcpSecretsManager) checkReplicationDrift(projectID, secretName string, secret *secretmanagerpb.Secret) {
+	policy := g.replicationPolicy(secretName)
+	if policy == nil {
+		return
+	}
+	expected := policy.replication()
+	if proto.Equal(expected, sortedReplicas(secret.Replication)) {
+		return
+	}
+	handler := g.driftHandler
+	if handler == nil {
+		handler = logDrift
+	}
+	handler(&ReplicationDrift{
+		ProjectID:  projectID,
+		SecretName: secretName,
+		Expected:   expected,
+		Actual:     secret.Replication,
+	})
+}
+
+// replication converts the policy to a Secret Manager replication, defaulting to automatic replication
+func (p *ReplicationPolicy) replication() *secretmanagerpb.Replication {
+	if p == nil || len(p.Locations) == 0 {
+		automatic := &secretmanagerpb.Replication_Automatic{}
+		if p != nil && p.KMSKeyName != "" {
+			automatic.CustomerManagedEncryption = &secretmanagerpb.CustomerManagedEncryption{KmsKeyName: p.KMSKeyName}
+		}
+		return &secretmanagerpb.Replication{
+			Replication: &secretmanagerpb.Replication_Automatic_{Automatic: automatic},
+		}
+	}
+
+	locations := append([]string(nil), p.Locations...)
+	sort.Strings(locations)
+	userManaged := &secretmanagerpb.Replication_UserManaged{}
+	for _, location := range locations {
+		replica := &secretmanagerpb.Replication_UserManaged_Replica{Location: location}
+		keyName := p.KMSKeyName
+		if k, ok := p.ReplicaKMSKeyNames[location]; ok {
+			keyName = k
+		}
+		if keyName != "" {
+			replica.CustomerManagedEncryption = &secretmanagerpb.CustomerManagedEncryption{KmsKeyName: keyName}
+		}
+		userManaged.Replicas = append(userManaged.Replicas, replica)
+	}
+	return &secretmanagerpb.Replication{
+		Replication: &secretmanagerpb.Replication_UserManaged_{UserManaged: userManaged},
+	}
+}
+
+// sortedReplicas returns a copy of the replication with user-managed replicas ordered by location
+func sortedReplicas(replication *secretmanagerpb.Replication) *secretmanagerpb.Replication {
+	userManaged := replication.GetUserManaged()
+	if userManaged == nil {
+		return replication
+	}
+	sorted := proto.Clone(replication).(*secretmanagerpb.Replication)
+	replicas := sorted.GetUserManaged().Replicas
+	sort.Slice(replicas, func(i, j int) bool {
+		return replicas[i].Location < replicas[j].Location
+	})
+	return sorted
+}
@@ -0,0 +1,55 @@
+package gcpsecretsmanager
+
+import (
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/google"
+)
+
+func TestReplicationPolicy(t *testing.T) {
+	var nilPolicy *ReplicationPolicy
+	assert.NotNil(t, nilPolicy.replication().GetAutomatic())
+
+	automatic := (&ReplicationPolicy{KMSKeyName: "global-key"}).replication().GetAutomatic()
+	require.NotNil(t, automatic)
+	assert.Equal(t, "global-key", automatic.CustomerManagedEncryption.KmsKeyName)
+
+	userManaged := (&ReplicationPolicy{
+		Locations:          []string{"europe-west2", "europe-west1"},
+		KMSKeyName:         "default-key",
+		ReplicaKMSKeyNames: map[string]string{"europe-west2": "london-key"},
+	}).replication().GetUserManaged()
+	require.NotNil(t, userManaged)
+	require.Len(t, userManaged.Replicas, 2)
+	assert.Equal(t, "europe-west1", userManaged.Replicas[0].Location)
+	assert.Equal(t, "default-key", userManaged.Replicas[0].CustomerManagedEncryption.KmsKeyName)
+	assert.Equal(t, "europe-west2", userManaged.Replicas[1].Location)
+	assert.Equal(t, "london-key", userManaged.Replicas[1].CustomerManagedEncryption.KmsKeyName)
+}
+
+func TestCheckReplicationDrift(t *testing.T) {
+	var drifts []*ReplicationDrift
+	g := NewGcpSecretsManager(google.Credentials{},
+		WithReplicationPolicy(ReplicationPolicy{Locations: []string{"europe-west1", "europe-west2"}}),
+		WithSecretReplicationPolicy("global", ReplicationPolicy{}),
+		WithDriftHandler(func(drift *ReplicationDrift) {
+			drifts = append(drifts, drift)
+		}),
+	).(*gcpSecretsManager)
+
+	pinned := &secretmanagerpb.Secret{Replication: &secretmanagerpb.Replication{
+		Replication: &secretmanagerpb.Replication_UserManaged_{UserManaged: &secretmanagerpb.Replication_UserManaged{
+			Replicas: []*secretmanagerpb.Replication_UserManaged_Replica{{Location: "europe-west2"}, {Location: "europe-west1"}},
+		}},
+	}}
+	g.checkReplicationDrift("project", "pinned", pinned)
+	assert.Empty(t, drifts, "replica order should not be reported as drift")
+
+	g.checkReplicationDrift("project", "global", pinned)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, "global", drifts[0].SecretName)
+	assert.NotNil(t, drifts[0].Expected.GetAutomatic())
+}
@@ -0,0 +1,192 @@
+package gcpsecretsmanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// States of a secret version
+const (
+	VersionEnabled   = "ENABLED"
+	VersionDisabled  = "DISABLED"
+	VersionDestroyed = "DESTROYED"
+)
+
+// SecretVersion describes a version of a GCP secret
+type SecretVersion struct {
+	// Version is the version number, e.g. 3
+	Version    string
+	State      string
+	CreateTime time.Time
+}
+
+// VersionInterface manages the versions of GCP secrets. It is implemented by the secret manager returned by
+// NewGcpSecretsManager.
+type VersionInterface interface {
+	// ListSecretVersions returns the versions of a secret, newest first
+	ListSecretVersions(projectID, secretName string) ([]SecretVersion, error)
+	DisableSecretVersion(projectID, secretName, version string) error
+	EnableSecretVersion(projectID, secretName, version string) error
+	// DestroySecretVersion irrevocably destroys the data of a version
+	DestroySecretVersion(projectID, secretName, version string) error
+	// PruneSecretVersions applies a retention policy to the versions of a secret
+	PruneSecretVersions(projectID, secretName string, policy RetentionPolicy) error
+}
+
+// RetentionPolicy limits the number of enabled versions of a secret
+type RetentionPolicy struct {
+	// KeepVersions is the number of most recent enabled versions to keep. Older versions are pruned.
+	KeepVersions int
+	// Destroy destroys pruned versions, including older versions that are already disabled, instead of disabling them
+	Destroy bool
+}
+
+// WithRetentionPolicy prunes the versions of a secret after each SetSecret. By default every version is kept. A
+// policy keeping fewer than one version keeps one, as the version SetSecret has just added can't be pruned.
+func WithRetentionPolicy(policy RetentionPolicy) Option {
+	return func(g *gcpSecretsManager) {
+		if policy.KeepVersions < 1 {
+			policy.KeepVersions = 1
+		}
+		g.retention = &policy
+	}
+}
+
+func (g *gcpSecretsManager) ListSecretVersions(projectID, secretName string) ([]SecretVersion, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return nil, err
+	}
+	versions, err := listSecretVersions(client, projectID, secretName)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]SecretVersion, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, SecretVersion{
+			Version:    path.Base(v.Name),
+			State:      v.State.String(),
+			CreateTime: v.CreateTime.AsTime(),
+		})
+	}
+	return result, nil
+}
+
+func (g *gcpSecretsManager) DisableSecretVersion(projectID, secretName, version string) error {
+	client, err := g.getClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.DisableSecretVersion(context.TODO(), &secretmanagerpb.DisableSecretVersionRequest{
+		Name: versionName(projectID, secretName, version),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error disabling version %s of secret %s in GCP secret manager project %s", version, secretName, projectID)
+	}
+	return nil
+}
+
+func (g *gcpSecretsManager) EnableSecretVersion(projectID, secretName, version string) error {
+	client, err := g.getClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.EnableSecretVersion(context.TODO(), &secretmanagerpb.EnableSecretVersionRequest{
+		Name: versionName(projectID, secretName, version),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error enabling version %s of secret %s in GCP secret manager project %s", version, secretName, projectID)
+	}
+	return nil
+}
+
+func (g *gcpSecretsManager) DestroySecretVersion(projectID, secretName, version string) error {
+	client, err := g.getClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.DestroySecretVersion(context.TODO(), &secretmanagerpb.DestroySecretVersionRequest{
+		Name: versionName(projectID, secretName, version),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error destroying version %s of secret %s in GCP secret manager project %s", version, secretName, projectID)
+	}
+	return nil
+}
+
+// PruneSecretVersions keeps the policy's number of most recent enabled versions and disables, or destroys, every
+// version older than those. Disabled versions newer than the oldest kept version are left alone.
+func (g *gcpSecretsManager) PruneSecretVersions(projectID, secretName string, policy RetentionPolicy) error {
+	if policy.KeepVersions < 1 {
+		return errors.Errorf("retention policy of secret %s must keep at least one version", secretName)
+	}
+	client, err := g.getClient()
+	if err != nil {
+		return err
+	}
+	versions, err := listSecretVersions(client, projectID, secretName)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, v := range versions {
+		if kept < policy.KeepVersions {
+			if v.State == secretmanagerpb.SecretVersion_ENABLED {
+				kept++
+			}
+			continue
+		}
+		version := path.Base(v.Name)
+		switch {
+		case v.State == secretmanagerpb.SecretVersion_DESTROYED:
+		case policy.Destroy:
+			err = g.DestroySecretVersion(projectID, secretName, version)
+		case v.State == secretmanagerpb.SecretVersion_ENABLED:
+			err = g.DisableSecretVersion(projectID, secretName, version)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "error pruning versions of secret %s", secretName)
+		}
+	}
+	return nil
+}
+
+// listSecretVersions returns every version of a secret, newest first
+func listSecretVersions(client *secretmanager.Client, projectID, secretName string) ([]*secretmanagerpb.SecretVersion, error) {
+	it := client.ListSecretVersions(context.TODO(), &secretmanagerpb.ListSecretVersionsRequest{
+		Parent: fmt.Sprintf("projects/%s/secrets/%s", projectID, secretName),
+	})
+	var versions []*secretmanagerpb.SecretVersion
+	for {
+		v, err := it.Next()
+		if err == iterator.Done {
+			sort.SliceStable(versions, func(i, j int) bool {
+				return versionNumber(versions[i]) > versionNumber(versions[j])
+			})
+			return versions, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing versions of secret %s in GCP secret manager project %s", secretName, projectID)
+		}
+		versions = append(versions, v)
+	}
+}
+
+func versionNumber(v *secretmanagerpb.SecretVersion) int {
+	n, _ := strconv.Atoi(path.Base(v.Name))
+	return n
+}
+
+func versionName(projectID, secretName, version string) string {
+	return fmt.Sprintf("projects/%s/secrets/%s/versions/%s", projectID, secretName, version)
+}
@@ -0,0 +1,250 @@
+package gcpsecretsmanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Event types published by Secret Manager to a secret's Pub/Sub notification topics
+const (
+	NotificationSecretUpdate         = "SECRET_UPDATE"
+	NotificationSecretDelete         = "SECRET_DELETE"
+	NotificationSecretVersionAdd     = "SECRET_VERSION_ADD"
+	NotificationSecretVersionEnable  = "SECRET_VERSION_ENABLE"
+	NotificationSecretVersionDisable = "SECRET_VERSION_DISABLE"
+	NotificationSecretVersionDestroy = "SECRET_VERSION_DESTROY"
+)
+
+// NotificationSubscriber receives Secret Manager notifications from a Pub/Sub subscription to a secret's topic.
+// Receive calls handle with the attributes of each message (eventType, secretId, versionId...) until ctx is
+// cancelled or an error occurs. It is satisfied by a thin adapter over a Pub/Sub client, or a Pub/Sub emulator, and
+// keeps this package free of a Pub/Sub dependency.
+type NotificationSubscriber interface {
+	Receive(ctx context.Context, handle func(attributes map[string]string)) error
+}
+
+// WithNotificationSubscriber makes Watch use Pub/Sub notifications instead of polling for new versions
+func WithNotificationSubscriber(subscriber NotificationSubscriber) Option {
+	return func(g *gcpSecretsManager) {
+		g.notifications = subscriber
+	}
+}
+
+// Watch reports changes to a secret. Notifications are used when a NotificationSubscriber has been configured,
+// otherwise the latest version of the secret is polled.
+//
+// Pub/Sub delivers each message of a subscription to only one of its receivers, so the watchers of a secret manager
+// share a single Receive call which passes each notification to the watchers of its secret. It is started by the first
+// watcher and stopped when the last watcher stops or the secret manager is closed.
+func (g *gcpSecretsManager) Watch(projectID, secretName string) (secretstore.Watcher, error) {
+	if g.notifications == nil {
+		return secretstore.NewPollingWatcher(projectID, secretName, secretstore.DefaultWatchPollInterval, func() (string, error) {
+			return g.latestVersion(projectID, secretName)
+		}), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &notificationWatcher{
+		g:          g,
+		secretID:   fmt.Sprintf("projects/%s/secrets/%s", projectID, secretName),
+		projectID:  projectID,
+		secretName: secretName,
+		result:     make(chan secretstore.Event),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	g.addWatcher(w)
+	return w, nil
+}
+
+// notificationReceiver is the Receive call shared by the watchers of a secret manager
+type notificationReceiver struct {
+	cancel context.CancelFunc
+}
+
+// addWatcher registers a watcher, starting the receiver if it isn't running
+func (g *gcpSecretsManager) addWatcher(w *notificationWatcher) {
+	g.watchLock.Lock()
+	defer g.watchLock.Unlock()
+	if g.watchers == nil {
+		g.watchers = map[string]map[*notificationWatcher]bool{}
+	}
+	if g.watchers[w.secretID] == nil {
+		g.watchers[w.secretID] = map[*notificationWatcher]bool{}
+	}
+	g.watchers[w.secretID][w] = true
+	if g.receiver == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.receiver = &notificationReceiver{cancel: cancel}
+		go g.receive(ctx, g.receiver)
+	}
+}
+
+// removeWatcher unregisters a watcher, stopping the receiver if it was the last one
+func (g *gcpSecretsManager) removeWatcher(w *notificationWatcher) {
+	g.watchLock.Lock()
+	defer g.watchLock.Unlock()
+	delete(g.watchers[w.secretID], w)
+	if len(g.watchers[w.secretID]) == 0 {
+		delete(g.watchers, w.secretID)
+	}
+	if len(g.watchers) == 0 && g.receiver != nil {
+		g.receiver.cancel()
+		g.receiver = nil
+	}
+}
+
+// secretWatchers returns the watchers of a secret
+func (g *gcpSecretsManager) secretWatchers(secretID string) []*notificationWatcher {
+	g.watchLock.Lock()
+	defer g.watchLock.Unlock()
+	watchers := make([]*notificationWatcher, 0, len(g.watchers[secretID]))
+	for w := range g.watchers[secretID] {
+		watchers = append(watchers, w)
+	}
+	return watchers
+}
+
+// stopWatchers stops the receiver and every watcher
+func (g *gcpSecretsManager) stopWatchers() {
+	g.watchLock.Lock()
+	var watchers []*notificationWatcher
+	for _, secretWatchers := range g.watchers {
+		for w := range secretWatchers {
+			watchers = append(watchers, w)
+		}
+	}
+	g.watchers = nil
+	if g.receiver != nil {
+		g.receiver.cancel()
+		g.receiver = nil
+	}
+	g.watchLock.Unlock()
+
+	for _, w := range watchers {
+		w.close()
+	}
+}
+
+// receive passes notifications to the watchers of their secret until the receiver is stopped. If the subscriber fails
+// the error is sent to every watcher, which are then stopped.
+func (g *gcpSecretsManager) receive(ctx context.Context, receiver *notificationReceiver) {
+	err := g.notifications.Receive(ctx, func(attributes map[string]string) {
+		event, ok := notificationEvent(attributes)
+		if !ok {
+			return
+		}
+		for _, w := range g.secretWatchers(attributes["secretId"]) {
+			e := event
+			e.Location = w.projectID
+			e.SecretName = w.secretName
+			w.send(e)
+		}
+	})
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+
+	g.watchLock.Lock()
+	if g.receiver != receiver {
+		g.watchLock.Unlock()
+		return
+	}
+	watchers := g.watchers
+	g.watchers = nil
+	g.receiver = nil
+	g.watchLock.Unlock()
+
+	for _, secretWatchers := range watchers {
+		for w := range secretWatchers {
+			w.send(secretstore.Event{Type: secretstore.EventTypeError, Location: w.projectID, SecretName: w.secretName, Err: err})
+			w.close()
+		}
+	}
+}
+
+func notificationEvent(attributes map[string]string) (secretstore.Event, bool) {
+	version := ""
+	if versionID := attributes["versionId"]; versionID != "" {
+		version = path.Base(versionID)
+	}
+	switch attributes["eventType"] {
+	case NotificationSecretVersionAdd, NotificationSecretVersionEnable, NotificationSecretVersionDisable,
+		NotificationSecretVersionDestroy, NotificationSecretUpdate:
+		return secretstore.Event{Type: secretstore.EventTypeModified, Version: version}, true
+	case NotificationSecretDelete:
+		return secretstore.Event{Type: secretstore.EventTypeDeleted, Version: version}, true
+	}
+	return secretstore.Event{}, false
+}
+
+type notificationWatcher struct {
+	g          *gcpSecretsManager
+	secretID   string
+	projectID  string
+	secretName string
+	result     chan secretstore.Event
+	ctx        context.Context
+	cancel     context.CancelFunc
+	// sendLock stops the result channel being closed while an event is sent
+	sendLock sync.Mutex
+	closed   bool
+}
+
+func (n *notificationWatcher) ResultChan() <-chan secretstore.Event {
+	return n.result
+}
+
+func (n *notificationWatcher) Stop() {
+	n.g.removeWatcher(n)
+	n.close()
+}
+
+// send passes an event to the caller unless the watcher is stopped first
+func (n *notificationWatcher) send(e secretstore.Event) {
+	n.sendLock.Lock()
+	defer n.sendLock.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.result <- e:
+	case <-n.ctx.Done():
+	}
+}
+
+// close unblocks any send and closes the result channel
+func (n *notificationWatcher) close() {
+	n.cancel()
+	n.sendLock.Lock()
+	defer n.sendLock.Unlock()
+	if !n.closed {
+		n.closed = true
+		close(n.result)
+	}
+}
+
+// latestVersion returns the version number the latest alias currently resolves to
+func (g *gcpSecretsManager) latestVersion(projectID, secretName string) (string, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return "", err
+	}
+
+	version, err := client.GetSecretVersion(context.TODO(), &secretmanagerpb.GetSecretVersionRequest{
+		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, secretName),
+	})
+	if isNotFound(err) {
+		return "", &secretstore.NotFoundError{Location: projectID, SecretName: secretName, Err: err}
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "error getting latest version of secret %s for GCP secrets manager project %s", secretName, projectID)
+	}
+	return path.Base(version.Name), nil
+}
@@ -0,0 +1,88 @@
+package gcpsecretsmanager_test
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/gcpsecretsmanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/google"
+)
+
+// fakeSubscriber delivers each message to only one of its receivers, as a Pub/Sub subscription does
+type fakeSubscriber struct {
+	messages  chan map[string]string
+	receivers int32
+}
+
+func (f *fakeSubscriber) Receive(ctx context.Context, handle func(attributes map[string]string)) error {
+	atomic.AddInt32(&f.receivers, 1)
+	defer atomic.AddInt32(&f.receivers, -1)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-f.messages:
+			handle(m)
+		}
+	}
+}
+
+func (f *fakeSubscriber) publish(secretName, version string) {
+	f.messages <- map[string]string{
+		"eventType": gcpsecretsmanager.NotificationSecretVersionAdd,
+		"secretId":  "projects/" + testProject + "/secrets/" + secretName,
+		"versionId": "projects/" + testProject + "/secrets/" + secretName + "/versions/" + version,
+	}
+}
+
+func TestWatchSharesOneReceiverBetweenSecrets(t *testing.T) {
+	subscriber := &fakeSubscriber{messages: make(chan map[string]string)}
+	mgr := gcpsecretsmanager.NewGcpSecretsManager(google.Credentials{}, gcpsecretsmanager.WithNotificationSubscriber(subscriber))
+	watcher := mgr.(secretstore.WatchInterface)
+
+	db, err := watcher.Watch(testProject, "db")
+	require.NoError(t, err)
+	api, err := watcher.Watch(testProject, "api")
+	require.NoError(t, err)
+
+	for i, version := range []string{"1", "2", "3"} {
+		if i%2 == 0 {
+			go subscriber.publish("db", version)
+			assertEvent(t, db, "db", version)
+		} else {
+			go subscriber.publish("api", version)
+			assertEvent(t, api, "api", version)
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&subscriber.receivers))
+
+	db.Stop()
+	_, ok := <-db.ResultChan()
+	assert.False(t, ok, "a stopped watcher should close its channel")
+	go subscriber.publish("api", "4")
+	assertEvent(t, api, "api", "4")
+
+	require.NoError(t, mgr.(io.Closer).Close())
+	_, ok = <-api.ResultChan()
+	assert.False(t, ok, "closing the secret manager should stop its watchers")
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&subscriber.receivers) == 0
+	}, 5*time.Second, 10*time.Millisecond, "the receiver should stop with the last watcher")
+}
+
+func assertEvent(t *testing.T, w secretstore.Watcher, secretName, version string) {
+	select {
+	case e := <-w.ResultChan():
+		assert.Equal(t, secretstore.EventTypeModified, e.Type)
+		assert.Equal(t, secretName, e.SecretName)
+		assert.Equal(t, version, e.Version)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for event", "secret %s version %s", secretName, version)
+	}
+}
@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	LowerCharacters  = "abcdefghijklmnopqrstuvwxyz"
+	UpperCharacters  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DigitCharacters  = "0123456789"
+	SymbolCharacters = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+
+	// DefaultPasswordLength is used when PasswordOptions does not specify a length
+	DefaultPasswordLength = 32
+)
+
+// Func generates a new secret value
+type Func func() (string, error)
+
+// PasswordOptions controls the length and character classes of generated passwords. If no character class is enabled
+// lower case, upper case and digits are used.
+type PasswordOptions struct {
+	Length  int
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+	// ExcludeCharacters are removed from every character class, e.g. to avoid ambiguous characters such as "0O1l"
+	ExcludeCharacters string
+}
+
+// Password generates a random password which contains at least one character from each enabled character class
+func Password(opts PasswordOptions) (string, error) {
+	length := opts.Length
+	if length == 0 {
+		length = DefaultPasswordLength
+	}
+	if !opts.Lower && !opts.Upper && !opts.Digits && !opts.Symbols {
+		opts.Lower, opts.Upper, opts.Digits = true, true, true
+	}
+
+	var classes []string
+	for _, c := range []struct {
+		enabled    bool
+		characters string
+	}{
+		{opts.Lower, LowerCharacters},
+		{opts.Upper, UpperCharacters},
+		{opts.Digits, DigitCharacters},
+		{opts.Symbols, SymbolCharacters},
+	} {
+		if !c.enabled {
+			continue
+		}
+		characters := removeCharacters(c.characters, opts.ExcludeCharacters)
+		if characters == "" {
+			return "", fmt.Errorf("every character of the class %q is excluded", c.characters)
+		}
+		classes = append(classes, characters)
+	}
+	if length < len(classes) {
+		return "", fmt.Errorf("password length %d is too short to include all %d character classes", length, len(classes))
+	}
+
+	// pick one character from each class so every class is represented, then fill the rest from all of them
+	password := make([]byte, 0, length)
+	for _, characters := range classes {
+		c, err := randomCharacter(characters)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	all := strings.Join(classes, "")
+	for len(password) < length {
+		c, err := randomCharacter(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// shuffle so the guaranteed characters are not always at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// PasswordFunc returns a Func which generates passwords with the given options
+func PasswordFunc(opts PasswordOptions) Func {
+	return func() (string, error) {
+		return Password(opts)
+	}
+}
+
+// RandomBytes returns n cryptographically secure random bytes
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading random bytes")
+	}
+	return b, nil
+}
+
+// Base64 returns n random bytes encoded as standard base64, e.g. for HMAC keys
+func Base64(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Hex returns n random bytes encoded as lower case hex
+func Hex(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UUID returns a random (version 4) UUID
+func UUID() (string, error) {
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return "", errors.Wrap(err, "error generating UUID")
+	}
+	return u.String(), nil
+}
+
+// Htpasswd returns an htpasswd entry for the user with a bcrypt hash of the password
+func Htpasswd(username, password string) (string, error) {
+	if strings.Contains(username, ":") {
+		return "", fmt.Errorf("htpasswd username %s must not contain ':'", username)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.Wrapf(err, "error hashing password for htpasswd user %s", username)
+	}
+	return fmt.Sprintf("%s:%s", username, hash), nil
+}
+
+func removeCharacters(characters, exclude string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(exclude, r) {
+			return -1
+		}
+		return r
+	}, characters)
+}
+
+func randomCharacter(characters string) (byte, error) {
+	i, err := randomInt(len(characters))
+	if err != nil {
+		return 0, err
+	}
+	return characters[i], nil
+}
+
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, errors.Wrap(err, "error generating random number")
+	}
+	return int(i.Int64()), nil
+}
@@ -0,0 +1,150 @@
+package generator_test
+
+import (
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPassword(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		p, err := generator.Password(generator.PasswordOptions{
+			Length:            12,
+			Lower:             true,
+			Digits:            true,
+			Symbols:           true,
+			ExcludeCharacters: "0O1l",
+		})
+		require.NoError(t, err)
+		assert.Len(t, p, 12)
+		assert.True(t, strings.ContainsAny(p, generator.LowerCharacters), "missing lower case in %s", p)
+		assert.True(t, strings.ContainsAny(p, generator.DigitCharacters), "missing digit in %s", p)
+		assert.True(t, strings.ContainsAny(p, generator.SymbolCharacters), "missing symbol in %s", p)
+		assert.False(t, strings.ContainsAny(p, "0O1l"+generator.UpperCharacters), "unexpected character in %s", p)
+	}
+
+	_, err := generator.Password(generator.PasswordOptions{Length: 2, Lower: true, Upper: true, Digits: true})
+	assert.Error(t, err)
+}
+
+func TestEncodedBytes(t *testing.T) {
+	b64, err := generator.Base64(32)
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(b64)
+	require.NoError(t, err)
+	assert.Len(t, decoded, 32)
+
+	h, err := generator.Hex(16)
+	require.NoError(t, err)
+	assert.Len(t, h, 32)
+
+	u, err := generator.UUID()
+	require.NoError(t, err)
+	assert.Len(t, u, 36)
+}
+
+func TestKeyPairs(t *testing.T) {
+	rsaKeys, err := generator.RSAKeyPair(2048)
+	require.NoError(t, err)
+	ecKeys, err := generator.ECDSAKeyPair(elliptic.P256())
+	require.NoError(t, err)
+	edKeys, err := generator.Ed25519KeyPair()
+	require.NoError(t, err)
+
+	for _, k := range []*generator.KeyPair{rsaKeys, ecKeys, edKeys} {
+		block, _ := pem.Decode([]byte(k.PrivateKey))
+		require.NotNil(t, block)
+		_, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		assert.NoError(t, err)
+
+		block, _ = pem.Decode([]byte(k.PublicKey))
+		require.NotNil(t, block)
+		_, err = x509.ParsePKIXPublicKey(block.Bytes)
+		assert.NoError(t, err)
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	entry, err := generator.Htpasswd("admin", "letmein")
+	require.NoError(t, err)
+	parts := strings.SplitN(entry, ":", 2)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "admin", parts[0])
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(parts[1]), []byte("letmein")))
+}
+
+func TestSetIfAbsent(t *testing.T) {
+	store := fake.NewFakeSecretStore()
+	err := store.SetSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"password": "existing"}})
+	require.NoError(t, err)
+
+	value, created, err := generator.SetIfAbsent(store, "ns", "db", "password", generator.PasswordFunc(generator.PasswordOptions{}))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "existing", value)
+
+	value, created, err = generator.SetIfAbsent(store, "ns", "hmac", "", func() (string, error) {
+		return generator.Base64(32)
+	})
+	require.NoError(t, err)
+	assert.True(t, created)
+	store.AssertValueEquals(t, "ns", "hmac", "", value)
+}
+
+func TestSetIfAbsentAddsMissingKeyToExistingSecret(t *testing.T) {
+	store := fake.NewFakeSecretStore()
+	err := store.SetSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "admin"}})
+	require.NoError(t, err)
+
+	value, created, err := generator.SetIfAbsent(store, "ns", "db", "password", fixedValue("generated"))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "generated", value)
+	store.AssertValueEquals(t, "ns", "db", "password", "generated")
+	store.AssertValueEquals(t, "ns", "db", "username", "admin")
+}
+
+func TestSetIfAbsentKeepsSecretCreatedConcurrently(t *testing.T) {
+	store := fake.NewFakeSecretStore()
+	racing := &racingSecretStore{SecretStore: store}
+
+	value, created, err := generator.SetIfAbsent(racing, "ns", "db", "password", fixedValue("generated"))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "concurrent", value)
+	store.AssertValueEquals(t, "ns", "db", "password", "concurrent")
+}
+
+func fixedValue(value string) generator.Func {
+	return func() (string, error) {
+		return value, nil
+	}
+}
+
+// racingSecretStore creates the secret after the first check for it, as a concurrent caller of SetIfAbsent would
+type racingSecretStore struct {
+	*fake.SecretStore
+	checked bool
+}
+
+func (r *racingSecretStore) GetSecret(location, secretName, secretKey string) (string, error) {
+	if r.checked {
+		return r.SecretStore.GetSecret(location, secretName, secretKey)
+	}
+	r.checked = true
+	err := r.SecretStore.SetSecret(location, secretName, &secretstore.SecretValue{PropertyValues: map[string]string{secretKey: "concurrent"}})
+	if err != nil {
+		return "", err
+	}
+	return "", &secretstore.NotFoundError{Location: location, SecretName: secretName, SecretKey: secretKey}
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PrivateKeyProperty and PublicKeyProperty are the property names used when storing a KeyPair as a secret
+	PrivateKeyProperty = "privateKey"
+	PublicKeyProperty  = "publicKey"
+)
+
+// KeyPair is a PEM encoded private key (PKCS #8) and its public key (PKIX)
+type KeyPair struct {
+	PrivateKey string
+	PublicKey  string
+}
+
+// PropertyValues returns the key pair as secret property values
+func (k *KeyPair) PropertyValues() map[string]string {
+	return map[string]string{
+		PrivateKeyProperty: k.PrivateKey,
+		PublicKeyProperty:  k.PublicKey,
+	}
+}
+
+// RSAKeyPair generates an RSA key pair with the given modulus size in bits
+func RSAKeyPair(bits int) (*KeyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating %d bit RSA key", bits)
+	}
+	return encodeKeyPair(key, key.Public())
+}
+
+// ECDSAKeyPair generates an ECDSA key pair on the given curve, e.g. elliptic.P256()
+func ECDSAKeyPair(curve elliptic.Curve) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating ECDSA key on curve %s", curve.Params().Name)
+	}
+	return encodeKeyPair(key, key.Public())
+}
+
+// Ed25519KeyPair generates an Ed25519 key pair
+func Ed25519KeyPair() (*KeyPair, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating Ed25519 key")
+	}
+	return encodeKeyPair(private, public)
+}
+
+func encodeKeyPair(private crypto.PrivateKey, public crypto.PublicKey) (*KeyPair, error) {
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling private key")
+	}
+	publicBytes, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling public key")
+	}
+	return &KeyPair{
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes})),
+	}, nil
+}
@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"encoding/json"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// SetIfAbsent generates and stores a secret value only when the secret (or the secretKey property within it) does
+// not already exist or is empty. It returns the value now held by the secret store and whether it was generated.
+// Errors other than the secret not existing are returned rather than risking an overwrite.
+//
+// A secret that doesn't exist is created with secretstore.CreateInterface when the secret manager implements it, so
+// when two callers race to create it only one value is stored and the other caller gets that value back, or a
+// secretstore.ConflictError if it can't be read yet. Other secret managers, such as Vault and Azure Key Vault, check
+// and write the secret separately so the last writer wins. A property missing from an existing secret is merged into
+// it, or added to a copy of its properties for secret managers that don't merge, such as AWS Parameter Store, which
+// loses any property written concurrently by another caller.
+func SetIfAbsent(mgr secretstore.Interface, location, secretName, secretKey string, generate Func) (string, bool, error) {
+	existing, err := mgr.GetSecret(location, secretName, secretKey)
+	if err != nil && !secretstore.IsNotFound(err) {
+		return "", false, errors.Wrapf(err, "error checking whether secret %s exists at location %s", secretName, location)
+	}
+	if err == nil && existing != "" {
+		return existing, false, nil
+	}
+
+	value, err := generate()
+	if err != nil {
+		return "", false, errors.Wrapf(err, "error generating value for secret %s", secretName)
+	}
+	secretValue := &secretstore.SecretValue{Value: value}
+	if secretKey != "" {
+		secretValue = &secretstore.SecretValue{PropertyValues: map[string]string{secretKey: value}}
+	}
+
+	if creator, ok := mgr.(secretstore.CreateInterface); ok {
+		err = creator.CreateSecret(location, secretName, secretValue)
+		if err == nil {
+			return value, true, nil
+		}
+		if !secretstore.IsConflict(err) {
+			return "", false, errors.Wrapf(err, "error creating generated secret %s at location %s", secretName, location)
+		}
+		// the secret exists, either without the value or because it was created since it was checked
+		existing, err = mgr.GetSecret(location, secretName, secretKey)
+		if err != nil && !secretstore.IsNotFound(err) {
+			return "", false, errors.Wrapf(err, "error reading secret %s at location %s after it was created concurrently", secretName, location)
+		}
+		if err == nil && existing != "" {
+			return existing, false, nil
+		}
+	}
+
+	err = setAbsentValue(mgr, location, secretName, secretKey, value)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "error setting generated secret %s at location %s", secretName, location)
+	}
+	return value, true, nil
+}
+
+// setAbsentValue writes a value that is missing or empty. An empty secret has nothing to keep so is overwritten, and
+// a property is merged into the secret, or added to its existing properties if the secret manager refuses the merge
+// because the secret already exists.
+func setAbsentValue(mgr secretstore.Interface, location, secretName, secretKey, value string) error {
+	if secretKey == "" {
+		return mgr.SetSecret(location, secretName, &secretstore.SecretValue{Value: value, Overwrite: true})
+	}
+	err := mgr.SetSecret(location, secretName, &secretstore.SecretValue{PropertyValues: map[string]string{secretKey: value}})
+	if !secretstore.IsConflict(err) {
+		return err
+	}
+
+	existing, err := mgr.GetSecret(location, secretName, "")
+	if err != nil {
+		return errors.Wrap(err, "error reading the existing properties")
+	}
+	properties, err := parseProperties(existing)
+	if err != nil {
+		return err
+	}
+	properties[secretKey] = value
+	return mgr.SetSecret(location, secretName, &secretstore.SecretValue{PropertyValues: properties, Overwrite: true})
+}
+
+// parseProperties parses the properties of a secret, keeping values that aren't strings as JSON text
+func parseProperties(value string) (map[string]string, error) {
+	properties := map[string]string{}
+	if value == "" {
+		return properties, nil
+	}
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal([]byte(value), &raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "existing secret is not a JSON object so the property can't be added to it")
+	}
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			s = string(v)
+		}
+		properties[k] = s
+	}
+	return properties, nil
+}
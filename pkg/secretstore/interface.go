@@ -8,3 +8,20 @@ type Interface interface {
 type FactoryInterface interface {
 	NewSecretManager(storeType Type) (Interface, error)
 }
+
+// MetadataInterface is implemented by secret managers which can read the metadata of a secret without its value
+type MetadataInterface interface {
+	GetSecretMetadata(location string, secretName string) (*SecretMetadata, error)
+}
+
+// WatchInterface is implemented by secret managers which can notify callers when a secret changes
+type WatchInterface interface {
+	Watch(location string, secretName string) (Watcher, error)
+}
+
+// CreateInterface is implemented by secret managers which can create a secret only if it doesn't already exist, so two
+// callers creating the same secret can't overwrite each other. CreateSecret returns a ConflictError when the secret
+// already exists.
+type CreateInterface interface {
+	CreateSecret(location string, secretName string, secretValue *SecretValue) error
+}
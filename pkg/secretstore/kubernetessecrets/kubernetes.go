@@ -2,7 +2,6 @@ package kubernetessecrets
 
 import (
 	"context"
-	"fmt"
 	"strings"
 
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
@@ -10,7 +9,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 const (
@@ -18,19 +20,47 @@ const (
 	ReplicateToAnnotation = "secret.jenkins-x.io/replicate-to"
 )
 
-func NewKubernetesSecretManager(kubeClient kubernetes.Interface) secretstore.Interface {
-	return &kubernetesSecretManager{kubeClient: kubeClient}
+func NewKubernetesSecretManager(kubeClient kubernetes.Interface, opts ...Option) secretstore.Interface {
+	k := &kubernetesSecretManager{kubeClient: kubeClient}
+	for _, o := range opts {
+		o(k)
+	}
+	return k
 }
 
 type kubernetesSecretManager struct {
-	kubeClient kubernetes.Interface
+	kubeClient         kubernetes.Interface
+	batchLabelSelector string
+}
+
+// Option configures a Kubernetes secret manager
+type Option func(*kubernetesSecretManager)
+
+// WithBatchLabelSelector makes GetSecrets list the Secrets matching the label selector in each namespace once, rather
+// than getting every Secret individually. Secrets outside the selector are still read individually.
+func WithBatchLabelSelector(selector string) Option {
+	return func(k *kubernetesSecretManager) {
+		k.batchLabelSelector = selector
+	}
 }
 
 func (k kubernetesSecretManager) GetSecret(namespace, secretName, secretKey string) (string, error) {
 	secret, err := k.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to get secret %s from namespace %s", secretName, namespace)
+		return "", getError(err, namespace, secretName)
+	}
+	return getSecretKey(secret, secretKey)
+}
+
+// getError converts the error of getting a Secret to a secretstore.NotFoundError if the Secret doesn't exist
+func getError(err error, namespace, secretName string) error {
+	if apierrors.IsNotFound(err) {
+		return &secretstore.NotFoundError{Location: namespace, SecretName: secretName, Err: err}
 	}
+	return errors.Wrapf(err, "failed to get secret %s from namespace %s", secretName, namespace)
+}
+
+func getSecretKey(secret *corev1.Secret, secretKey string) (string, error) {
 	secretData, ok := secret.Data[secretKey]
 	if ok {
 		return string(secretData), nil
@@ -39,7 +69,68 @@ func (k kubernetesSecretManager) GetSecret(namespace, secretName, secretKey stri
 	if ok {
 		return secretString, nil
 	}
-	return "", fmt.Errorf("failed to get secret %s from namespace %s", secretName, namespace)
+	return "", &secretstore.NotFoundError{Location: secret.Namespace, SecretName: secret.Name, SecretKey: secretKey}
+}
+
+// GetSecrets lists the Secrets matching the batch label selector of each namespace once, getting the others
+// individually. Without a selector every Secret is got individually, as listing would read every Secret in the
+// namespace and needs permission to list them.
+func (k kubernetesSecretManager) GetSecrets(refs []secretstore.SecretRef, concurrency int) []secretstore.GetResult {
+	if k.batchLabelSelector == "" {
+		return secretstore.ParallelGetSecrets(k, refs, concurrency)
+	}
+	results := make([]secretstore.GetResult, len(refs))
+	var missing []int
+	locations, byLocation := secretstore.GroupRefsByLocation(refs)
+	for _, namespace := range locations {
+		list, err := k.kubeClient.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: k.batchLabelSelector})
+		if err != nil {
+			// e.g. listing Secrets is forbidden, so get them individually
+			missing = append(missing, byLocation[namespace]...)
+			continue
+		}
+		secrets := map[string]*corev1.Secret{}
+		for i := range list.Items {
+			secrets[list.Items[i].Name] = &list.Items[i]
+		}
+		for _, i := range byLocation[namespace] {
+			secret, ok := secrets[refs[i].SecretName]
+			if !ok {
+				// Secrets excluded by the label selector may still exist
+				missing = append(missing, i)
+				continue
+			}
+			value, err := getSecretKey(secret, refs[i].SecretKey)
+			results[i] = secretstore.GetResult{Ref: refs[i], Value: value, Err: err}
+		}
+	}
+
+	missingRefs := make([]secretstore.SecretRef, 0, len(missing))
+	for _, i := range missing {
+		missingRefs = append(missingRefs, refs[i])
+	}
+	for j, result := range secretstore.ParallelGetSecrets(k, missingRefs, concurrency) {
+		results[missing[j]] = result
+	}
+	return results
+}
+
+// SetSecrets writes the Secrets from a pool of workers
+func (k kubernetesSecretManager) SetSecrets(writes []secretstore.SecretWrite, concurrency int) []secretstore.SetResult {
+	return secretstore.ParallelSetSecrets(k, writes, concurrency)
+}
+
+func (k kubernetesSecretManager) GetSecretMetadata(namespace, secretName string) (*secretstore.SecretMetadata, error) {
+	secret, err := k.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, getError(err, namespace, secretName)
+	}
+	return &secretstore.SecretMetadata{
+		Labels:      secret.Labels,
+		Annotations: secret.Annotations,
+		Version:     secret.ResourceVersion,
+		CreateTime:  secret.CreationTimestamp.Time,
+	}, nil
 }
 
 func (k kubernetesSecretManager) SetSecret(namespace, secretName string, secretValue *secretstore.SecretValue) error {
@@ -51,15 +142,51 @@ func (k kubernetesSecretManager) SetSecret(namespace, secretName string, secretV
 			return errors.Wrapf(err, "failed to ")
 		}
 		create = true
-		secret = &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      secretName,
-				Namespace: namespace,
-			},
-			Type: corev1.SecretTypeOpaque,
+		secret = newSecret(namespace, secretName)
+	}
+
+	applySecretValue(secret, secretValue)
+
+	if create {
+		_, err = secretInterface.Create(context.TODO(), secret, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create Secret %s in namespace %s", secretName, namespace)
+		}
+	} else {
+		_, err = secretInterface.Update(context.TODO(), secret, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update Secret %s in namespace %s", secretName, namespace)
 		}
 	}
+	return k.replicateSecret(secret, secretValue)
+}
+
+// CreateSecret creates the Secret, returning a secretstore.ConflictError if it already exists
+func (k kubernetesSecretManager) CreateSecret(namespace, secretName string, secretValue *secretstore.SecretValue) error {
+	secret := newSecret(namespace, secretName)
+	applySecretValue(secret, secretValue)
+	_, err := k.kubeClient.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return &secretstore.ConflictError{Location: namespace, SecretName: secretName, Err: err}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to create Secret %s in namespace %s", secretName, namespace)
+	}
+	return k.replicateSecret(secret, secretValue)
+}
+
+func newSecret(namespace, secretName string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
 
+// applySecretValue merges the property values, labels and annotations of the secret value into the Secret
+func applySecretValue(secret *corev1.Secret, secretValue *secretstore.SecretValue) {
 	secret.Type = corev1.SecretTypeOpaque
 	if string(secretValue.SecretType) != "" {
 		secret.Type = secretValue.SecretType
@@ -88,26 +215,27 @@ func (k kubernetesSecretManager) SetSecret(namespace, secretName string, secretV
 			secret.Annotations[k] = v
 		}
 	}
-
-	if create {
-		_, err = secretInterface.Create(context.TODO(), secret, metav1.CreateOptions{})
-		if err != nil {
-			return errors.Wrapf(err, "failed to create Secret %s in namespace %s", secretName, namespace)
+	for _, k := range secretValue.RemoveLabels {
+		if _, ok := secretValue.Labels[k]; !ok {
+			delete(secret.Labels, k)
 		}
-	} else {
-		_, err = secretInterface.Update(context.TODO(), secret, metav1.UpdateOptions{})
-		if err != nil {
-			return errors.Wrapf(err, "failed to update Secret %s in namespace %s", secretName, namespace)
+	}
+	for _, k := range secretValue.RemoveAnnotations {
+		if _, ok := secretValue.Annotations[k]; !ok {
+			delete(secret.Annotations, k)
 		}
 	}
+}
 
+// replicateSecret copies the Secret to the namespaces listed in the ReplicateToAnnotation of the secret value
+func (k kubernetesSecretManager) replicateSecret(secret *corev1.Secret, secretValue *secretstore.SecretValue) error {
 	// lets check for replicated secrets
 	if secretValue.Annotations != nil {
 		namespaces := secretValue.Annotations[ReplicateToAnnotation]
 		if namespaces != "" {
 			nsList := strings.Split(namespaces, ",")
 			for _, tons := range nsList {
-				err = copySecretToNamespace(k.kubeClient, tons, secret)
+				err := copySecretToNamespace(k.kubeClient, tons, secret)
 				if err != nil {
 					return errors.Wrapf(err, "failed to replicate Secret for local backend")
 				}
@@ -180,3 +308,111 @@ func copySecretToNamespace(kubeClient kubernetes.Interface, ns string, fromSecre
 	}
 	return nil
 }
+
+// Watch uses a Kubernetes watch on the Secret. The watch starts from the current state of the Secret so only later
+// changes are reported, and is re-established from the last seen resource version whenever the API server closes it.
+func (k kubernetesSecretManager) Watch(namespace, secretName string) (secretstore.Watcher, error) {
+	secretInterface := k.kubeClient.CoreV1().Secrets(namespace)
+	listOptions := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", secretName).String()}
+	w, resourceVersion, err := startWatch(context.TODO(), secretInterface, listOptions, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to watch secret %s in namespace %s", secretName, namespace)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sw := &secretWatcher{result: make(chan secretstore.Event), cancel: cancel}
+	go func() {
+		defer close(sw.result)
+		for {
+			var ok bool
+			resourceVersion, ok = forwardEvents(ctx, w, resourceVersion, namespace, secretName, sw.result)
+			w.Stop()
+			if !ok {
+				return
+			}
+			w, resourceVersion, err = startWatch(ctx, secretInterface, listOptions, resourceVersion)
+			if err != nil {
+				select {
+				case sw.result <- secretstore.Event{Type: secretstore.EventTypeError, Location: namespace, SecretName: secretName, Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+	return sw, nil
+}
+
+// startWatch watches from the given resource version, or from the current state if it is empty, and returns the
+// resource version the watch started from
+func startWatch(ctx context.Context, secretInterface typedcorev1.SecretInterface, listOptions metav1.ListOptions, resourceVersion string) (watch.Interface, string, error) {
+	if resourceVersion == "" {
+		list, err := secretInterface.List(ctx, listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		resourceVersion = list.ResourceVersion
+	}
+	listOptions.ResourceVersion = resourceVersion
+	w, err := secretInterface.Watch(ctx, listOptions)
+	if err != nil {
+		return nil, "", err
+	}
+	return w, resourceVersion, nil
+}
+
+type secretWatcher struct {
+	result chan secretstore.Event
+	cancel context.CancelFunc
+}
+
+func (s *secretWatcher) ResultChan() <-chan secretstore.Event {
+	return s.result
+}
+
+func (s *secretWatcher) Stop() {
+	s.cancel()
+}
+
+// forwardEvents translates Kubernetes watch events until the watch closes, returning the last resource version seen
+// and false if the watcher has been stopped
+func forwardEvents(ctx context.Context, w watch.Interface, resourceVersion, namespace, secretName string, result chan<- secretstore.Event) (string, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion, false
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return resourceVersion, true
+			}
+			event := secretstore.Event{Location: namespace, SecretName: secretName}
+			switch e.Type {
+			case watch.Added:
+				event.Type = secretstore.EventTypeAdded
+			case watch.Modified:
+				event.Type = secretstore.EventTypeModified
+			case watch.Deleted:
+				event.Type = secretstore.EventTypeDeleted
+			case watch.Error:
+				err := apierrors.FromObject(e.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					// start again from the current state
+					return "", true
+				}
+				event.Type = secretstore.EventTypeError
+				event.Err = err
+			default:
+				continue
+			}
+			if secret, ok := e.Object.(*corev1.Secret); ok {
+				event.Version = secret.ResourceVersion
+				resourceVersion = secret.ResourceVersion
+			}
+			select {
+			case <-ctx.Done():
+				return resourceVersion, false
+			case result <- event:
+			}
+		}
+	}
+}
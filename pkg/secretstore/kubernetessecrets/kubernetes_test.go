@@ -0,0 +1,152 @@
+package kubernetessecrets_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/kubernetessecrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWatch(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	mgr := kubernetessecrets.NewKubernetesSecretManager(kubeClient)
+	watcher, ok := mgr.(secretstore.WatchInterface)
+	require.True(t, ok)
+
+	w, err := watcher.Watch("ns", "db")
+	require.NoError(t, err)
+	defer w.Stop()
+
+	err = mgr.SetSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"password": "a"}})
+	require.NoError(t, err)
+	err = mgr.SetSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"password": "b"}})
+	require.NoError(t, err)
+
+	for _, expected := range []secretstore.EventType{secretstore.EventTypeAdded, secretstore.EventTypeModified} {
+		select {
+		case e := <-w.ResultChan():
+			assert.Equal(t, expected, e.Type)
+			assert.Equal(t, "ns", e.Location)
+			assert.Equal(t, "db", e.SecretName)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for event", "expected %s", expected)
+		}
+	}
+}
+
+func TestGetSecrets(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	mgr := kubernetessecrets.NewKubernetesSecretManager(kubeClient, kubernetessecrets.WithBatchLabelSelector("app=db"))
+	err := mgr.SetSecret("ns", "labelled", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"user": "admin"},
+		Labels:         map[string]string{"app": "db"},
+	})
+	require.NoError(t, err)
+	err = mgr.SetSecret("ns", "unlabelled", &secretstore.SecretValue{PropertyValues: map[string]string{"user": "guest"}})
+	require.NoError(t, err)
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: "ns", SecretName: "labelled", SecretKey: "user"},
+		{Location: "ns", SecretName: "unlabelled", SecretKey: "user"},
+		{Location: "ns", SecretName: "labelled", SecretKey: "password"},
+		{Location: "other", SecretName: "labelled", SecretKey: "user"},
+	}, 2)
+	require.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "admin", results[0].Value)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, "guest", results[1].Value)
+	assert.True(t, secretstore.IsNotFound(results[2].Err))
+	assert.True(t, secretstore.IsNotFound(results[3].Err))
+	assert.Equal(t, "other", results[3].Ref.Location)
+}
+
+func TestGetSecretsWithoutSelectorDoesNotList(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	mgr := kubernetessecrets.NewKubernetesSecretManager(kubeClient)
+	err := mgr.SetSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"user": "admin"}})
+	require.NoError(t, err)
+	kubeClient.ClearActions()
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{{Location: "ns", SecretName: "db", SecretKey: "user"}}, 2)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "admin", results[0].Value)
+	for _, action := range kubeClient.Actions() {
+		assert.NotEqual(t, "list", action.GetVerb())
+	}
+}
+
+func TestGetSecretsFallsBackWhenListIsForbidden(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("list", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", nil)
+	})
+	mgr := kubernetessecrets.NewKubernetesSecretManager(kubeClient, kubernetessecrets.WithBatchLabelSelector("app=db"))
+	err := mgr.SetSecret("ns", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"user": "admin"},
+		Labels:         map[string]string{"app": "db"},
+	})
+	require.NoError(t, err)
+
+	results := secretstore.GetSecrets(mgr, []secretstore.SecretRef{
+		{Location: "ns", SecretName: "db", SecretKey: "user"},
+		{Location: "ns", SecretName: "missing", SecretKey: "user"},
+	}, 2)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "admin", results[0].Value)
+	assert.True(t, secretstore.IsNotFound(results[1].Err))
+}
+
+func TestSetSecretMergesLabelsAndAnnotations(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	mgr := kubernetessecrets.NewKubernetesSecretManager(kubeClient)
+	err := mgr.SetSecret("ns", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"user": "admin"},
+		Labels:         map[string]string{"app": "db", "tier": "backend"},
+		Annotations:    map[string]string{"owner": "platform"},
+	})
+	require.NoError(t, err)
+	err = mgr.SetSecret("ns", "db", &secretstore.SecretValue{
+		PropertyValues:    map[string]string{"user": "admin"},
+		Labels:            map[string]string{"env": "prod"},
+		RemoveLabels:      []string{"tier"},
+		RemoveAnnotations: []string{"owner"},
+	})
+	require.NoError(t, err)
+
+	metadata, err := mgr.(secretstore.MetadataInterface).GetSecretMetadata("ns", "db")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "db", "env": "prod"}, metadata.Labels)
+	assert.Empty(t, metadata.Annotations)
+}
+
+func TestCreateSecret(t *testing.T) {
+	mgr := kubernetessecrets.NewKubernetesSecretManager(fake.NewSimpleClientset())
+	creator, ok := mgr.(secretstore.CreateInterface)
+	require.True(t, ok)
+
+	require.NoError(t, creator.CreateSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "admin"}}))
+	err := creator.CreateSecret("ns", "db", &secretstore.SecretValue{PropertyValues: map[string]string{"username": "root"}})
+	assert.True(t, secretstore.IsConflict(err))
+
+	password, created, err := generator.SetIfAbsent(mgr, "ns", "db", "password", func() (string, error) {
+		return "generated", nil
+	})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "generated", password)
+	username, err := mgr.GetSecret("ns", "db", "username")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+}
@@ -0,0 +1,16 @@
+package secretstore
+
+import "time"
+
+// SecretMetadata describes a secret without exposing its value
+type SecretMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	// Version identifies the current version of the secret in the backend's own format
+	Version    string
+	CreateTime time.Time
+	// UpdateTime is zero when the backend does not record when a secret was last changed
+	UpdateTime time.Time
+	// Lifecycle is the expiry and rotation schedule of the secret, nil if it has none or the backend has no equivalent
+	Lifecycle *SecretLifecycle
+}
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultPasswordKeyPatterns are the glob patterns used to decide whether a key looks like a password when a policy
+// does not declare its own
+var DefaultPasswordKeyPatterns = []string{"*password*", "*passwd*", "*secret*", "*token*", "*apikey*", "*api_key*"}
+
+// Policy declares the rules a secret must satisfy before it is written to a secret store
+type Policy struct {
+	// MinPasswordEntropy is the minimum estimated entropy in bits for values whose keys look like passwords
+	MinPasswordEntropy float64 `json:"minPasswordEntropy,omitempty"`
+	// PasswordKeyPatterns are case-insensitive glob patterns matched against property keys (or the secret name for
+	// simple values) to decide whether the entropy rule applies
+	PasswordKeyPatterns []string `json:"passwordKeyPatterns,omitempty"`
+	// RequiredLabels are the label keys which must be present with a non empty value
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+	// ForbiddenKeys are glob patterns of property keys which must not be written
+	ForbiddenKeys []string `json:"forbiddenKeys,omitempty"`
+	// AllowedLocations are glob patterns of locations secrets may be written to, any location is allowed if empty
+	AllowedLocations []string `json:"allowedLocations,omitempty"`
+	// MaxSizeBytes is the maximum size of the serialised secret, unlimited if zero
+	MaxSizeBytes int `json:"maxSizeBytes,omitempty"`
+
+	// patterns are the compiled glob patterns, set by ParsePolicy
+	patterns *compiledPatterns
+}
+
+// compiledPatterns are the glob patterns of a policy compiled to regular expressions
+type compiledPatterns struct {
+	passwordKeys     []*regexp.Regexp
+	forbiddenKeys    []*regexp.Regexp
+	allowedLocations []*regexp.Regexp
+}
+
+// Violation describes a single rule a secret failed to satisfy
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// ValidationError is returned when a secret fails to satisfy a policy and lists every violation found
+type ValidationError struct {
+	Location   string
+	SecretName string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s: %s", v.Rule, v.Message))
+	}
+	return fmt.Sprintf("secret %s at location %s violates policy: %s", e.SecretName, e.Location, strings.Join(messages, "; "))
+}
+
+const (
+	RuleMinPasswordEntropy = "minPasswordEntropy"
+	RuleRequiredLabels     = "requiredLabels"
+	RuleForbiddenKeys      = "forbiddenKeys"
+	RuleAllowedLocations   = "allowedLocations"
+	RuleMaxSizeBytes       = "maxSizeBytes"
+)
+
+// LoadPolicyFile reads a YAML or JSON policy from the given file
+func LoadPolicyFile(fileName string) (*Policy, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading secret policy file %s", fileName)
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing secret policy file %s", fileName)
+	}
+	return p, nil
+}
+
+// ParsePolicy parses a YAML or JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := &Policy{}
+	err := yaml.UnmarshalStrict(data, p)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling secret policy")
+	}
+	p.patterns = p.compilePatterns()
+	return p, nil
+}
+
+// compilePatterns compiles the glob patterns of the policy, using the default password key patterns if it has none
+func (p *Policy) compilePatterns() *compiledPatterns {
+	passwordKeyPatterns := p.PasswordKeyPatterns
+	if len(passwordKeyPatterns) == 0 {
+		passwordKeyPatterns = DefaultPasswordKeyPatterns
+	}
+	return &compiledPatterns{
+		passwordKeys:     compileGlobs(passwordKeyPatterns),
+		forbiddenKeys:    compileGlobs(p.ForbiddenKeys),
+		allowedLocations: compileGlobs(p.AllowedLocations),
+	}
+}
+
+// Validate returns every violation of the policy by the given secret, or nil if the secret is compliant
+func (p *Policy) Validate(location, secretName string, secretValue *secretstore.SecretValue) []Violation {
+	patterns := p.patterns
+	if patterns == nil {
+		// the policy wasn't parsed so is compiled for this call only
+		patterns = p.compilePatterns()
+	}
+
+	var violations []Violation
+	if len(p.AllowedLocations) > 0 && !matchesAny(patterns.allowedLocations, location) {
+		violations = append(violations, Violation{
+			Rule:    RuleAllowedLocations,
+			Message: fmt.Sprintf("location %s does not match any of %s", location, strings.Join(p.AllowedLocations, ", ")),
+		})
+	}
+
+	for _, label := range p.RequiredLabels {
+		if secretValue.Labels[label] == "" {
+			violations = append(violations, Violation{
+				Rule:    RuleRequiredLabels,
+				Message: fmt.Sprintf("missing required label %s", label),
+			})
+		}
+	}
+
+	keys := make([]string, 0, len(secretValue.PropertyValues))
+	for k := range secretValue.PropertyValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if matchesAny(patterns.forbiddenKeys, k) {
+			violations = append(violations, Violation{
+				Rule:    RuleForbiddenKeys,
+				Message: fmt.Sprintf("key %s is forbidden", k),
+			})
+		}
+	}
+
+	if p.MinPasswordEntropy > 0 {
+		if secretValue.Value != "" {
+			if matchesAny(patterns.passwordKeys, secretName) {
+				violations = append(violations, p.checkEntropy(secretName, secretValue.Value)...)
+			}
+		} else {
+			for _, k := range keys {
+				if matchesAny(patterns.passwordKeys, k) {
+					violations = append(violations, p.checkEntropy(k, secretValue.PropertyValues[k])...)
+				}
+			}
+		}
+	}
+
+	if p.MaxSizeBytes > 0 {
+		size := len(secretValue.ToString())
+		if size > p.MaxSizeBytes {
+			violations = append(violations, Violation{
+				Rule:    RuleMaxSizeBytes,
+				Message: fmt.Sprintf("secret is %d bytes which exceeds the maximum of %d", size, p.MaxSizeBytes),
+			})
+		}
+	}
+	return violations
+}
+
+func (p *Policy) checkEntropy(key, value string) []Violation {
+	entropy := Entropy(value)
+	if entropy >= p.MinPasswordEntropy {
+		return nil
+	}
+	return []Violation{{
+		Rule:    RuleMinPasswordEntropy,
+		Message: fmt.Sprintf("value of %s has an estimated entropy of %.1f bits which is below the minimum of %.1f", key, entropy, p.MinPasswordEntropy),
+	}}
+}
+
+// Entropy estimates the entropy in bits of a value from the Shannon entropy of its characters multiplied by its length
+func Entropy(value string) float64 {
+	runes := []rune(value)
+	if len(runes) == 0 {
+		return 0
+	}
+	counts := map[rune]int{}
+	for _, r := range runes {
+		counts[r]++
+	}
+	total := float64(len(runes))
+	perSymbol := 0.0
+	for _, c := range counts {
+		f := float64(c) / total
+		perSymbol -= f * math.Log2(f)
+	}
+	return perSymbol * total
+}
+
+// compileGlobs compiles case-insensitive glob patterns, where * matches any sequence of characters (including /) and ?
+// matches a single character
+func compileGlobs(patterns []string) []*regexp.Regexp {
+	exprs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		exprs = append(exprs, regexp.MustCompile("(?i)^"+expr+"$"))
+	}
+	return exprs
+}
+
+// matchesAny reports whether s matches any of the compiled patterns
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
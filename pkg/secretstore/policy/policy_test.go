@@ -0,0 +1,160 @@
+package policy_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/gcpsecretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/policy"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fake"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fakegcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/google"
+)
+
+const testPolicy = `
+minPasswordEntropy: 40
+requiredLabels:
+- owner
+- env
+forbiddenKeys:
+- "aws_*"
+allowedLocations:
+- "team-*"
+maxSizeBytes: 50
+`
+
+func TestValidatingSecretManagerRejectsNonCompliantSecret(t *testing.T) {
+	p, err := policy.ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	store := fake.NewFakeSecretStore()
+	mgr := policy.NewValidatingSecretManager(store, p)
+
+	err = mgr.SetSecret("other", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{
+			"password":       "password",
+			"aws_access_key": "AKIAEXAMPLE",
+		},
+		Labels: map[string]string{"owner": "platform"},
+	})
+	require.Error(t, err)
+	validationErr, ok := err.(*policy.ValidationError)
+	require.True(t, ok, "expected a *policy.ValidationError but got %T", err)
+
+	var rules []string
+	for _, v := range validationErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	assert.ElementsMatch(t, []string{
+		policy.RuleAllowedLocations,
+		policy.RuleRequiredLabels,
+		policy.RuleForbiddenKeys,
+		policy.RuleMinPasswordEntropy,
+		policy.RuleMaxSizeBytes,
+	}, rules)
+
+	_, err = store.GetSecret("other", "db", "password")
+	assert.Error(t, err, "rejected secret should not have been written")
+}
+
+func TestValidatingSecretManagerAllowsCompliantSecret(t *testing.T) {
+	p, err := policy.ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	store := fake.NewFakeSecretStore()
+	mgr := policy.NewValidatingSecretManager(store, p)
+
+	err = mgr.SetSecret("team-a", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{
+			"username": "app",
+			"password": "x9#Lq2!vR7@pZ4$w",
+		},
+		Labels: map[string]string{"owner": "platform", "env": "prod"},
+	})
+	require.NoError(t, err)
+	store.AssertValueEquals(t, "team-a", "db", "username", "app")
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPolicy), 0o600))
+
+	p, err := policy.LoadPolicyFile(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, 50, p.MaxSizeBytes)
+	assert.Equal(t, []string{"owner", "env"}, p.RequiredLabels)
+
+	_, err = policy.ParsePolicy([]byte("maxSize: 1"))
+	assert.Error(t, err, "unknown fields should be rejected")
+}
+
+func TestEntropy(t *testing.T) {
+	assert.Equal(t, 0.0, policy.Entropy("aaaa"))
+	assert.InDelta(t, 24.0, policy.Entropy("abcd1234"), 0.001)
+}
+
+func TestValidatingSecretManagerPassesThroughInterfaces(t *testing.T) {
+	p, err := policy.ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	store := fake.NewFakeSecretStore()
+	mgr := policy.NewValidatingSecretManager(store, p)
+
+	_, ok := mgr.(secretstore.MetadataInterface)
+	assert.True(t, ok, "metadata of the wrapped secret manager should be readable")
+	_, ok = mgr.(secretstore.WatchInterface)
+	assert.False(t, ok, "the wrapped secret manager can't watch secrets")
+	_, ok = mgr.(secretstore.BatchInterface)
+	assert.True(t, ok)
+	_, ok = mgr.(secretstore.ContextInterface)
+	assert.True(t, ok)
+}
+
+func TestValidatingSecretManagerValidatesBatchWrites(t *testing.T) {
+	p, err := policy.ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	store := fake.NewFakeSecretStore()
+	mgr := policy.NewValidatingSecretManager(store, p)
+
+	labels := map[string]string{"owner": "platform", "env": "prod"}
+	results := secretstore.SetSecrets(mgr, []secretstore.SecretWrite{
+		{Location: "other", SecretName: "db", SecretValue: &secretstore.SecretValue{Value: "x", Labels: labels}},
+		{Location: "team-a", SecretName: "db", SecretValue: &secretstore.SecretValue{Value: "x", Labels: labels}},
+	}, 2)
+	require.Len(t, results, 2)
+	var validationErr *policy.ValidationError
+	assert.ErrorAs(t, results[0].Err, &validationErr)
+	assert.NoError(t, results[1].Err)
+
+	_, err = store.GetSecretMetadata("other", "db")
+	assert.True(t, secretstore.IsNotFound(err), "rejected secret should not have been written")
+	store.AssertValueEquals(t, "team-a", "db", "", "x")
+}
+
+func TestValidatingSecretManagerClosesAndUnwrapsGCPManager(t *testing.T) {
+	server, err := fakegcp.NewSecretManagerServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Stop)
+	gcp := gcpsecretsmanager.NewGcpSecretsManager(google.Credentials{},
+		gcpsecretsmanager.WithEndpoint(server.Addr()), gcpsecretsmanager.WithInsecure())
+	p, err := policy.ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+	mgr := policy.NewValidatingSecretManager(gcp, p)
+
+	err = mgr.SetSecret("team-a", "db", &secretstore.SecretValue{
+		Value:  "x",
+		Labels: map[string]string{"owner": "platform", "env": "prod"},
+	})
+	require.NoError(t, err)
+
+	unwrapped := mgr.(interface{ Unwrap() secretstore.Interface }).Unwrap()
+	versions, err := unwrapped.(gcpsecretsmanager.VersionInterface).ListSecretVersions("team-a", "db")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	closer, ok := mgr.(io.Closer)
+	require.True(t, ok, "the GCP client should be closable through the wrapper")
+	assert.NoError(t, closer.Close())
+}
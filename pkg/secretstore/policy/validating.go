@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"context"
+	"io"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+)
+
+// NewValidatingSecretManager wraps a secret manager so that every SetSecret call is checked against the policy first.
+// Non-compliant writes are rejected with a *ValidationError and never reach the underlying store.
+//
+// The returned secret manager implements secretstore.BatchInterface, validating every write of a batch, and
+// secretstore.ContextInterface, passing the context to the wrapped secret manager if it takes one. It implements
+// secretstore.MetadataInterface and secretstore.WatchInterface when the wrapped secret manager does, and io.Closer,
+// closing the wrapped secret manager if it can be closed. Other optional interfaces, such as the versions and stages of
+// a backend, are reached through Unwrap; writes made that way bypass the policy.
+func NewValidatingSecretManager(secretManager secretstore.Interface, policy *Policy) secretstore.Interface {
+	v := &validatingSecretManager{secretManager: secretManager, policy: policy}
+	metadataMgr, hasMetadata := secretManager.(secretstore.MetadataInterface)
+	watchMgr, hasWatch := secretManager.(secretstore.WatchInterface)
+	switch {
+	case hasMetadata && hasWatch:
+		return struct {
+			*validatingSecretManager
+			secretstore.MetadataInterface
+			secretstore.WatchInterface
+		}{v, metadataMgr, watchMgr}
+	case hasMetadata:
+		return struct {
+			*validatingSecretManager
+			secretstore.MetadataInterface
+		}{v, metadataMgr}
+	case hasWatch:
+		return struct {
+			*validatingSecretManager
+			secretstore.WatchInterface
+		}{v, watchMgr}
+	}
+	return v
+}
+
+type validatingSecretManager struct {
+	secretManager secretstore.Interface
+	policy        *Policy
+}
+
+func (v *validatingSecretManager) GetSecret(location, secretName, secretKey string) (string, error) {
+	return v.secretManager.GetSecret(location, secretName, secretKey)
+}
+
+func (v *validatingSecretManager) SetSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
+	if err := v.validate(location, secretName, secretValue); err != nil {
+		return err
+	}
+	return v.secretManager.SetSecret(location, secretName, secretValue)
+}
+
+// Unwrap returns the wrapped secret manager
+func (v *validatingSecretManager) Unwrap() secretstore.Interface {
+	return v.secretManager
+}
+
+// Close closes the wrapped secret manager if it implements io.Closer
+func (v *validatingSecretManager) Close() error {
+	if closer, ok := v.secretManager.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// GetSecretWithContext reads the secret with the context if the wrapped secret manager takes one
+func (v *validatingSecretManager) GetSecretWithContext(ctx context.Context, location, secretName, secretKey string) (string, error) {
+	return secretstore.GetSecretWithContext(ctx, v.secretManager, location, secretName, secretKey)
+}
+
+// SetSecretWithContext validates the write and passes it to the wrapped secret manager with the context
+func (v *validatingSecretManager) SetSecretWithContext(ctx context.Context, location, secretName string, secretValue *secretstore.SecretValue) error {
+	if err := v.validate(location, secretName, secretValue); err != nil {
+		return err
+	}
+	return secretstore.SetSecretWithContext(ctx, v.secretManager, location, secretName, secretValue)
+}
+
+// GetSecrets reads the secrets with the batch support of the wrapped secret manager, if it has any
+func (v *validatingSecretManager) GetSecrets(refs []secretstore.SecretRef, concurrency int) []secretstore.GetResult {
+	return secretstore.GetSecrets(v.secretManager, refs, concurrency)
+}
+
+// SetSecrets rejects the non-compliant writes and passes the others to the wrapped secret manager as one batch
+func (v *validatingSecretManager) SetSecrets(writes []secretstore.SecretWrite, concurrency int) []secretstore.SetResult {
+	results := make([]secretstore.SetResult, len(writes))
+	var compliant []secretstore.SecretWrite
+	var indexes []int
+	for i, w := range writes {
+		results[i].Write = w
+		if err := v.validate(w.Location, w.SecretName, w.SecretValue); err != nil {
+			results[i].Err = err
+			continue
+		}
+		compliant = append(compliant, w)
+		indexes = append(indexes, i)
+	}
+	if len(compliant) == 0 {
+		return results
+	}
+	for j, result := range secretstore.SetSecrets(v.secretManager, compliant, concurrency) {
+		results[indexes[j]].Err = result.Err
+	}
+	return results
+}
+
+func (v *validatingSecretManager) validate(location, secretName string, secretValue *secretstore.SecretValue) error {
+	violations := v.policy.Validate(location, secretName, secretValue)
+	if len(violations) > 0 {
+		return &ValidationError{
+			Location:   location,
+			SecretName: secretName,
+			Violations: violations,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package rotation
+
+import (
+	"crypto/elliptic"
+	"fmt"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+)
+
+// Secret identifies a secret being rotated
+type Secret struct {
+	Location string
+	Name     string
+}
+
+// Rotator performs the steps of rotating a single secret. The Runner calls Generate and Test, writes the new value to
+// the secret store, calls Activate and finally calls Retire with the value it replaced.
+type Rotator interface {
+	// Generate creates the new secret value
+	Generate(secret Secret) (*secretstore.SecretValue, error)
+	// Test checks the new value is usable before it is activated
+	Test(secret Secret, candidate *secretstore.SecretValue) error
+	// Activate makes the new value live in whatever system consumes it, e.g. by changing a database password. If it
+	// fails the previous value is stored again.
+	Activate(secret Secret, candidate *secretstore.SecretValue) error
+	// Retire revokes the previous value once the new one has been stored. previous holds the values of the rotated
+	// keys before rotation and is nil if the secret did not exist.
+	Retire(secret Secret, previous *secretstore.SecretValue) error
+}
+
+// Hooks are optional callbacks used by the built-in rotators for the steps which depend on the consuming system.
+// A nil hook is a no-op.
+type Hooks struct {
+	TestFunc     func(secret Secret, candidate *secretstore.SecretValue) error
+	ActivateFunc func(secret Secret, candidate *secretstore.SecretValue) error
+	RetireFunc   func(secret Secret, previous *secretstore.SecretValue) error
+}
+
+func (h *Hooks) Test(secret Secret, candidate *secretstore.SecretValue) error {
+	if h.TestFunc == nil {
+		return nil
+	}
+	return h.TestFunc(secret, candidate)
+}
+
+func (h *Hooks) Activate(secret Secret, candidate *secretstore.SecretValue) error {
+	if h.ActivateFunc == nil {
+		return nil
+	}
+	return h.ActivateFunc(secret, candidate)
+}
+
+func (h *Hooks) Retire(secret Secret, previous *secretstore.SecretValue) error {
+	if h.RetireFunc == nil {
+		return nil
+	}
+	return h.RetireFunc(secret, previous)
+}
+
+// PasswordRotator rotates a random password. The password is stored under Key, or as the secret's simple value if
+// Key is empty.
+type PasswordRotator struct {
+	Hooks
+	Key     string
+	Options generator.PasswordOptions
+}
+
+func (p *PasswordRotator) Generate(_ Secret) (*secretstore.SecretValue, error) {
+	password, err := generator.Password(p.Options)
+	if err != nil {
+		return nil, err
+	}
+	if p.Key == "" {
+		return &secretstore.SecretValue{Value: password}, nil
+	}
+	return &secretstore.SecretValue{PropertyValues: map[string]string{p.Key: password}}, nil
+}
+
+// KeyAlgorithm is the type of key pair generated by a KeyPairRotator
+type KeyAlgorithm string
+
+const (
+	KeyAlgorithmRSA     KeyAlgorithm = "RSA"
+	KeyAlgorithmECDSA   KeyAlgorithm = "ECDSA"
+	KeyAlgorithmEd25519 KeyAlgorithm = "Ed25519"
+
+	defaultRSABits = 4096
+)
+
+// KeyPairRotator rotates a key pair stored as the generator.PrivateKeyProperty and generator.PublicKeyProperty
+// properties of a secret
+type KeyPairRotator struct {
+	Hooks
+	Algorithm KeyAlgorithm
+	// RSABits is the RSA modulus size, defaulting to 4096
+	RSABits int
+	// Curve is the ECDSA curve, defaulting to P-256
+	Curve elliptic.Curve
+}
+
+func (k *KeyPairRotator) Generate(_ Secret) (*secretstore.SecretValue, error) {
+	var keyPair *generator.KeyPair
+	var err error
+	switch k.Algorithm {
+	case KeyAlgorithmRSA:
+		bits := k.RSABits
+		if bits == 0 {
+			bits = defaultRSABits
+		}
+		keyPair, err = generator.RSAKeyPair(bits)
+	case KeyAlgorithmECDSA:
+		curve := k.Curve
+		if curve == nil {
+			curve = elliptic.P256()
+		}
+		keyPair, err = generator.ECDSAKeyPair(curve)
+	case KeyAlgorithmEd25519:
+		keyPair, err = generator.Ed25519KeyPair()
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", k.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &secretstore.SecretValue{PropertyValues: keyPair.PropertyValues()}, nil
+}
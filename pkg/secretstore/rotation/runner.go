@@ -0,0 +1,259 @@
+package rotation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RotatedAtLabel records when a secret was last rotated, as seconds since the Unix epoch. It is a label rather than
+	// an annotation as AWS Secrets Manager keeps annotations in the description of a secret, and its key and value are
+	// restricted to the characters GCP and Kubernetes allow in labels.
+	RotatedAtLabel = "jenkins-x-rotated-at"
+
+	// RotatedAtAnnotation recorded when a secret was last rotated, formatted as RFC 3339.
+	//
+	// Deprecated: rotations are recorded in RotatedAtLabel. The annotation is still read from secrets that have no
+	// RotatedAtLabel.
+	RotatedAtAnnotation = "secret.jenkins-x.io/rotated-at"
+)
+
+// Policy declares that a secret should be rotated by Rotator once it is older than MaxAge
+type Policy struct {
+	Secret
+	MaxAge  time.Duration
+	Rotator Rotator
+}
+
+// Result is the outcome of applying a single Policy
+type Result struct {
+	Policy  Policy
+	Rotated bool
+	Err     error
+}
+
+// Runner rotates the secrets whose age exceeds their policy. The age of a secret is taken from its RotatedAtLabel,
+// falling back to its creation time, so the secret manager must implement secretstore.MetadataInterface, as the AWS
+// Secrets Manager, GCP Secret Manager and Kubernetes secret managers do. AWS Parameter Store, Vault and Azure Key Vault
+// secrets can't be rotated by a Runner. Secrets which don't exist yet, or whose last rotation failed, are rotated.
+type Runner struct {
+	SecretManager secretstore.Interface
+	Policies      []Policy
+	// Now returns the current time, defaulting to time.Now
+	Now func() time.Time
+}
+
+// RunOnce applies every policy once and returns a result per policy. A failure rotating one secret does not stop
+// the others from being rotated.
+func (r *Runner) RunOnce() []Result {
+	results := make([]Result, 0, len(r.Policies))
+	for _, p := range r.Policies {
+		rotated, err := r.apply(p)
+		results = append(results, Result{Policy: p, Rotated: rotated, Err: err})
+	}
+	return results
+}
+
+// Run applies the policies every interval until the context is cancelled
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, result := range r.RunOnce() {
+			switch {
+			case result.Err != nil:
+				log.Logger().Errorf("failed to rotate secret %s at location %s: %v", result.Policy.Name, result.Policy.Location, result.Err)
+			case result.Rotated:
+				log.Logger().Infof("rotated secret %s at location %s", result.Policy.Name, result.Policy.Location)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Due reports whether the secret covered by the policy needs rotating
+func (r *Runner) Due(p Policy) (bool, error) {
+	metadataMgr, ok := r.SecretManager.(secretstore.MetadataInterface)
+	if !ok {
+		return false, errors.Errorf("secret manager %T can't read the metadata of secret %s at location %s so can't tell when it was last rotated", r.SecretManager, p.Name, p.Location)
+	}
+	metadata, err := metadataMgr.GetSecretMetadata(p.Location, p.Name)
+	if err != nil {
+		if secretstore.IsNotFound(err) {
+			return true, nil
+		}
+		return false, errors.Wrapf(err, "error reading metadata of secret %s at location %s", p.Name, p.Location)
+	}
+	lastRotated := metadata.CreateTime
+	if rotatedAt, ok := metadata.Labels[RotatedAtLabel]; ok {
+		if rotatedAt == "" {
+			// the secret was never rotated successfully
+			return true, nil
+		}
+		seconds, err := strconv.ParseInt(rotatedAt, 10, 64)
+		if err != nil {
+			return false, errors.Wrapf(err, "error parsing %s label of secret %s at location %s", RotatedAtLabel, p.Name, p.Location)
+		}
+		lastRotated = time.Unix(seconds, 0)
+	} else if rotatedAt, ok := metadata.Annotations[RotatedAtAnnotation]; ok {
+		if rotatedAt == "" {
+			return true, nil
+		}
+		lastRotated, err = time.Parse(time.RFC3339, rotatedAt)
+		if err != nil {
+			return false, errors.Wrapf(err, "error parsing %s annotation of secret %s at location %s", RotatedAtAnnotation, p.Name, p.Location)
+		}
+	}
+	if lastRotated.IsZero() {
+		return true, nil
+	}
+	return r.now().Sub(lastRotated) >= p.MaxAge, nil
+}
+
+func (r *Runner) apply(p Policy) (bool, error) {
+	due, err := r.Due(p)
+	if err != nil || !due {
+		return false, err
+	}
+	err = r.Rotate(p.Secret, p.Rotator)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Rotate rotates a secret immediately regardless of its age. The new value is stored before it is activated, so the
+// consuming system never uses a value that isn't stored, and the previous value is stored again if activation fails.
+func (r *Runner) Rotate(secret Secret, rotator Rotator) error {
+	candidate, err := rotator.Generate(secret)
+	if err != nil {
+		return errors.Wrapf(err, "error generating new value for secret %s", secret.Name)
+	}
+	previous, err := r.currentValue(secret, candidate)
+	if err != nil {
+		return err
+	}
+	rotatedAt, labelled, err := r.lastRotatedAt(secret)
+	if err != nil {
+		return err
+	}
+
+	err = rotator.Test(secret, candidate)
+	if err != nil {
+		return errors.Wrapf(err, "new value for secret %s failed testing", secret.Name)
+	}
+
+	if candidate.Labels == nil {
+		candidate.Labels = map[string]string{}
+	}
+	candidate.Labels[RotatedAtLabel] = strconv.FormatInt(r.now().Unix(), 10)
+	err = r.SecretManager.SetSecret(secret.Location, secret.Name, candidate)
+	if err != nil {
+		return errors.Wrapf(err, "error storing rotated secret %s at location %s", secret.Name, secret.Location)
+	}
+
+	err = rotator.Activate(secret, candidate)
+	if err != nil {
+		if rollbackErr := r.rollback(secret, previous, candidate, rotatedAt, labelled); rollbackErr != nil {
+			return errors.Wrapf(err, "error activating new value for secret %s, and restoring its previous value failed: %v", secret.Name, rollbackErr)
+		}
+		return errors.Wrapf(err, "error activating new value for secret %s", secret.Name)
+	}
+
+	err = rotator.Retire(secret, previous)
+	if err != nil {
+		return errors.Wrapf(err, "error retiring previous value of secret %s", secret.Name)
+	}
+	return nil
+}
+
+// rollback stores the values the rotated keys had before a rotation whose activation failed, along with the previous
+// RotatedAtLabel, or without the label if it had none, so the secret is still due. A secret which didn't exist keeps
+// the new value, as there is nothing to restore, with an empty RotatedAtLabel so it is rotated again.
+func (r *Runner) rollback(secret Secret, previous, candidate *secretstore.SecretValue, rotatedAt string, labelled bool) error {
+	restore := previous
+	switch {
+	case restore == nil:
+		restore = &secretstore.SecretValue{Value: candidate.Value, PropertyValues: candidate.PropertyValues}
+		restore.Labels = map[string]string{RotatedAtLabel: ""}
+	case labelled:
+		restore.Labels = map[string]string{RotatedAtLabel: rotatedAt}
+	default:
+		restore.RemoveLabels = []string{RotatedAtLabel}
+	}
+	err := r.SecretManager.SetSecret(secret.Location, secret.Name, restore)
+	if err != nil {
+		return errors.Wrapf(err, "error restoring secret %s at location %s", secret.Name, secret.Location)
+	}
+	return nil
+}
+
+// lastRotatedAt returns the RotatedAtLabel of a secret and whether it has one, which it doesn't if the secret manager
+// can't read metadata
+func (r *Runner) lastRotatedAt(secret Secret) (string, bool, error) {
+	metadataMgr, ok := r.SecretManager.(secretstore.MetadataInterface)
+	if !ok {
+		return "", false, nil
+	}
+	metadata, err := metadataMgr.GetSecretMetadata(secret.Location, secret.Name)
+	if err != nil {
+		if secretstore.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrapf(err, "error reading metadata of secret %s at location %s", secret.Name, secret.Location)
+	}
+	rotatedAt, ok := metadata.Labels[RotatedAtLabel]
+	return rotatedAt, ok, nil
+}
+
+// currentValue reads the values of the keys about to be rotated so they can be retired afterwards
+func (r *Runner) currentValue(secret Secret, candidate *secretstore.SecretValue) (*secretstore.SecretValue, error) {
+	keys := []string{""}
+	if candidate.Value == "" {
+		keys = keys[:0]
+		for k := range candidate.PropertyValues {
+			keys = append(keys, k)
+		}
+	}
+
+	previous := &secretstore.SecretValue{PropertyValues: map[string]string{}}
+	found := false
+	for _, k := range keys {
+		value, err := r.SecretManager.GetSecret(secret.Location, secret.Name, k)
+		if err != nil {
+			if secretstore.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "error reading current value of secret %s at location %s", secret.Name, secret.Location)
+		}
+		if value == "" {
+			continue
+		}
+		found = true
+		if k == "" {
+			previous.Value = value
+		} else {
+			previous.PropertyValues[k] = value
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return previous, nil
+}
+
+func (r *Runner) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
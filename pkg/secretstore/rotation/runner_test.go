@@ -0,0 +1,194 @@
+package rotation_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/awssecretsmanager"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/generator"
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore/rotation"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fake"
+	"github.com/jenkins-x-plugins/secretfacade/testing/fakeaws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerRotatesSecretsOlderThanPolicy(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := fake.NewFakeSecretStore()
+	for name, rotatedAt := range map[string]time.Time{
+		"stale": now.Add(-48 * time.Hour),
+		"fresh": now.Add(-time.Hour),
+	} {
+		err := store.SetSecret("ns", name, &secretstore.SecretValue{
+			PropertyValues: map[string]string{"password": "old-" + name},
+			Labels:         map[string]string{rotation.RotatedAtLabel: strconv.FormatInt(rotatedAt.Unix(), 10)},
+		})
+		require.NoError(t, err)
+	}
+
+	var steps []string
+	var retired *secretstore.SecretValue
+	rotator := &rotation.PasswordRotator{
+		Key: "password",
+		Hooks: rotation.Hooks{
+			TestFunc: func(secret rotation.Secret, _ *secretstore.SecretValue) error {
+				steps = append(steps, "test "+secret.Name)
+				return nil
+			},
+			ActivateFunc: func(secret rotation.Secret, _ *secretstore.SecretValue) error {
+				steps = append(steps, "activate "+secret.Name)
+				return nil
+			},
+			RetireFunc: func(secret rotation.Secret, previous *secretstore.SecretValue) error {
+				steps = append(steps, "retire "+secret.Name)
+				retired = previous
+				return nil
+			},
+		},
+	}
+
+	runner := rotation.Runner{
+		SecretManager: store,
+		Now:           func() time.Time { return now },
+		Policies: []rotation.Policy{
+			{Secret: rotation.Secret{Location: "ns", Name: "stale"}, MaxAge: 24 * time.Hour, Rotator: rotator},
+			{Secret: rotation.Secret{Location: "ns", Name: "fresh"}, MaxAge: 24 * time.Hour, Rotator: rotator},
+			{Secret: rotation.Secret{Location: "ns", Name: "new"}, MaxAge: 24 * time.Hour, Rotator: rotator},
+		},
+	}
+	results := runner.RunOnce()
+	require.Len(t, results, 3)
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+	}
+	assert.True(t, results[0].Rotated)
+	assert.False(t, results[1].Rotated)
+	assert.True(t, results[2].Rotated)
+
+	assert.Equal(t, []string{"test stale", "activate stale", "retire stale", "test new", "activate new", "retire new"}, steps)
+	assert.Nil(t, retired, "a secret which did not exist has nothing to retire")
+
+	password, err := store.GetSecret("ns", "stale", "password")
+	require.NoError(t, err)
+	assert.NotEqual(t, "old-stale", password)
+	assert.Len(t, password, generator.DefaultPasswordLength)
+
+	metadata, err := store.GetSecretMetadata("ns", "stale")
+	require.NoError(t, err)
+	assert.Equal(t, strconv.FormatInt(now.Unix(), 10), metadata.Labels[rotation.RotatedAtLabel])
+	store.AssertValueEquals(t, "ns", "fresh", "password", "old-fresh")
+}
+
+func TestRunnerStopsRotationWhenTestFails(t *testing.T) {
+	store := fake.NewFakeSecretStore()
+	err := store.SetSecret("ns", "keys", &secretstore.SecretValue{
+		PropertyValues: map[string]string{generator.PrivateKeyProperty: "old"},
+	})
+	require.NoError(t, err)
+
+	runner := rotation.Runner{SecretManager: store}
+	err = runner.Rotate(rotation.Secret{Location: "ns", Name: "keys"}, &rotation.KeyPairRotator{
+		Algorithm: rotation.KeyAlgorithmEd25519,
+		Hooks: rotation.Hooks{
+			TestFunc: func(rotation.Secret, *secretstore.SecretValue) error {
+				return fmt.Errorf("login failed")
+			},
+		},
+	})
+	assert.Error(t, err)
+	store.AssertValueEquals(t, "ns", "keys", generator.PrivateKeyProperty, "old")
+}
+
+func TestRunnerRestoresPreviousValueWhenActivationFails(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	rotatedAt := strconv.FormatInt(now.Add(-48*time.Hour).Unix(), 10)
+	store := fake.NewFakeSecretStore()
+	err := store.SetSecret("ns", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "old"},
+		Labels:         map[string]string{rotation.RotatedAtLabel: rotatedAt},
+	})
+	require.NoError(t, err)
+
+	var stored string
+	retired := false
+	rotator := &rotation.PasswordRotator{
+		Key: "password",
+		Hooks: rotation.Hooks{
+			ActivateFunc: func(rotation.Secret, *secretstore.SecretValue) error {
+				var err error
+				stored, err = store.GetSecret("ns", "db", "password")
+				require.NoError(t, err)
+				return fmt.Errorf("database unavailable")
+			},
+			RetireFunc: func(rotation.Secret, *secretstore.SecretValue) error {
+				retired = true
+				return nil
+			},
+		},
+	}
+	policy := rotation.Policy{Secret: rotation.Secret{Location: "ns", Name: "db"}, MaxAge: 24 * time.Hour, Rotator: rotator}
+	runner := rotation.Runner{SecretManager: store, Now: func() time.Time { return now }, Policies: []rotation.Policy{policy}}
+
+	results := runner.RunOnce()
+	require.Len(t, results, 1)
+	assert.Error(t, results[0].Err)
+	assert.False(t, results[0].Rotated)
+	assert.NotEqual(t, "old", stored, "the new value should be stored before it is activated")
+	assert.False(t, retired)
+
+	store.AssertValueEquals(t, "ns", "db", "password", "old")
+	due, err := runner.Due(policy)
+	require.NoError(t, err)
+	assert.True(t, due, "a secret whose rotation failed should still be due")
+	metadata, err := store.GetSecretMetadata("ns", "db")
+	require.NoError(t, err)
+	assert.Equal(t, rotatedAt, metadata.Labels[rotation.RotatedAtLabel])
+}
+
+func TestRunnerReadsLegacyRotatedAtAnnotation(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := fake.NewFakeSecretStore()
+	err := store.SetSecret("ns", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "old"},
+		Annotations:    map[string]string{rotation.RotatedAtAnnotation: now.Add(-time.Hour).Format(time.RFC3339)},
+	})
+	require.NoError(t, err)
+
+	runner := rotation.Runner{SecretManager: store, Now: func() time.Time { return now }}
+	due, err := runner.Due(rotation.Policy{Secret: rotation.Secret{Location: "ns", Name: "db"}, MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.False(t, due)
+}
+
+func TestRunnerKeepsAWSDescription(t *testing.T) {
+	server := fakeaws.NewSecretsManagerServer()
+	defer server.Close()
+	mgr := awssecretsmanager.NewAwsSecretManagerFromConfig(fakeaws.NewConfig(),
+		awssecretsmanager.WithEndpoint(server.URL), awssecretsmanager.WithHTTPClient(server.Client()))
+	err := mgr.SetSecret("eu-west-1", "db", &secretstore.SecretValue{
+		PropertyValues: map[string]string{"password": "old"},
+		Annotations:    map[string]string{awssecretsmanager.DescriptionAnnotation: "database password"},
+	})
+	require.NoError(t, err)
+
+	runner := rotation.Runner{SecretManager: mgr}
+	err = runner.Rotate(rotation.Secret{Location: "eu-west-1", Name: "db"}, &rotation.PasswordRotator{Key: "password"})
+	require.NoError(t, err)
+
+	metadata, err := mgr.(secretstore.MetadataInterface).GetSecretMetadata("eu-west-1", "db")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{awssecretsmanager.DescriptionAnnotation: "database password"}, metadata.Annotations)
+	assert.NotEmpty(t, metadata.Labels[rotation.RotatedAtLabel])
+}
+
+func TestRunnerRequiresMetadata(t *testing.T) {
+	mgr := struct{ secretstore.Interface }{fake.NewFakeSecretStore()}
+	runner := rotation.Runner{SecretManager: mgr}
+
+	_, err := runner.Due(rotation.Policy{Secret: rotation.Secret{Location: "ns", Name: "db"}, MaxAge: time.Hour})
+	assert.Error(t, err)
+}
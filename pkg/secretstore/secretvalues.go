@@ -2,6 +2,7 @@ package secretstore
 
 import (
 	"encoding/json"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -14,16 +15,43 @@ type SecretValue struct {
 	Annotations    map[string]string
 	Labels         map[string]string
 
+	// RemoveLabels and RemoveAnnotations are the keys of the labels and annotations to remove from an existing secret.
+	// The labels and annotations written are otherwise merged into those the secret already has.
+	RemoveLabels      []string
+	RemoveAnnotations []string
+
+	// BinaryValue is used instead of Value for secrets that aren't text. Backends that distinguish binary secrets
+	// store it as such, others store its bytes as they would a string.
+	BinaryValue []byte
+
 	// SecretType is only really needed when using local secrets so that we
 	// can populate the Secret resource with the correct type
 	SecretType corev1.SecretType
 	Overwrite  bool
+
+	// Lifecycle optionally declares when the secret expires and how often it is rotated, for backends that support it
+	Lifecycle *SecretLifecycle
+}
+
+// SecretLifecycle declares the expiry and rotation schedule of a secret. Backends that have no equivalent ignore it.
+type SecretLifecycle struct {
+	// ExpireTime is when the secret is deleted. Only one of ExpireTime and TTL may be set.
+	ExpireTime time.Time
+	// TTL is how long after being written the secret is deleted
+	TTL time.Duration
+	// RotationPeriod is how often the secret should be rotated
+	RotationPeriod time.Duration
+	// NextRotationTime is when the secret should next be rotated, defaulting to RotationPeriod from now
+	NextRotationTime time.Time
 }
 
 func (sv *SecretValue) ToString() string {
 	if sv.Value != "" {
 		return sv.Value
 	}
+	if sv.BinaryValue != nil {
+		return string(sv.BinaryValue)
+	}
 	j, err := json.Marshal(sv.PropertyValues)
 	if err != nil {
 		return "{}"
@@ -32,12 +60,9 @@ func (sv *SecretValue) ToString() string {
 }
 
 func (sv *SecretValue) MergeExistingSecret(existing map[string]string) string {
-	if existing == nil {
+	if existing == nil || sv.Value != "" || sv.BinaryValue != nil {
 		return sv.ToString()
 	}
-	if sv.Value != "" {
-		return sv.Value
-	}
 	err := mergo.Merge(&existing, sv.PropertyValues, mergo.WithOverride)
 	if err != nil {
 		return "{}"
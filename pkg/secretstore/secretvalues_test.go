@@ -0,0 +1,16 @@
+package secretstore_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryValue(t *testing.T) {
+	keystore := []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x02}
+	sv := &secretstore.SecretValue{BinaryValue: keystore}
+
+	assert.Equal(t, keystore, []byte(sv.ToString()))
+	assert.Equal(t, keystore, []byte(sv.MergeExistingSecret(map[string]string{"a": "b"})))
+}
@@ -2,6 +2,7 @@ package vaultsecrets
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
@@ -10,23 +11,60 @@ import (
 )
 
 func NewVaultSecretManager(client *api.Client) (secretstore.Interface, error) {
-	return &vaultSecretManager{client}, nil
+	return &vaultSecretManager{vaultAPI: client, clients: &clientCache{clients: map[string]*api.Client{}}}, nil
 }
 
 type vaultSecretManager struct {
 	vaultAPI *api.Client
+	clients  *clientCache
+}
+
+// clientCache holds a clone of the client per Vault address, so calls to different locations, including those of
+// polling watchers, don't change the address of a shared client
+type clientCache struct {
+	lock    sync.Mutex
+	clients map[string]*api.Client
+}
+
+// client returns the client of the Vault at location, cloning it from the client the secret manager was created with
+// on first use. The token is copied on every call as the original client's token may have been renewed.
+func (v vaultSecretManager) client(location string) (*api.Client, error) {
+	v.clients.lock.Lock()
+	defer v.clients.lock.Unlock()
+	c, ok := v.clients.clients[location]
+	if !ok {
+		var err error
+		c, err = v.vaultAPI.CloneWithHeaders()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error cloning Hashicorp Vault client for %s", location)
+		}
+		err = c.SetAddress(location)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error setting location of Hashicorp vault %s on client", location)
+		}
+		v.clients.clients[location] = c
+	}
+	c.SetToken(v.vaultAPI.Token())
+	return c, nil
 }
 
 func (v vaultSecretManager) GetSecret(location, secretName, secretKey string) (string, error) {
-	secret, err := getSecret(v.vaultAPI, location, secretName)
-	if err != nil || secret == nil {
+	client, err := v.client(location)
+	if err != nil {
+		return "", err
+	}
+	secret, err := getSecret(client, location, secretName)
+	if err != nil {
 		return "", errors.Wrapf(err, "error getting secret %s from Hasicorp vault %s", secretName, location)
 	}
+	if secret == nil {
+		return "", &secretstore.NotFoundError{Location: location, SecretName: secretName}
+	}
 	mapData, err := getSecretData(secret)
 	if err != nil {
 		return "", errors.Wrapf(err, "error converting secret data retrieved for secret %s from Hashicorp Vault %s", secretName, location)
 	}
-	secretString, err := getSecretKeyString(mapData, secretKey)
+	secretString, err := getSecretKeyString(location, secretName, mapData, secretKey)
 	if err != nil {
 		return "", errors.Wrapf(err, "error converting string data for secret %s from Hashicorp Vault %s", secretName, location)
 	}
@@ -34,7 +72,11 @@ func (v vaultSecretManager) GetSecret(location, secretName, secretKey string) (s
 }
 
 func (v vaultSecretManager) SetSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
-	secret, err := getSecret(v.vaultAPI, location, secretName)
+	client, err := v.client(location)
+	if err != nil {
+		return err
+	}
+	secret, err := getSecret(client, location, secretName)
 	if err != nil {
 		return errors.Wrapf(err, "error getting secret %s in Hashicorp vault %s prior to setting", secretName, location)
 	}
@@ -56,7 +98,7 @@ func (v vaultSecretManager) SetSecret(location, secretName string, secretValue *
 		"data": newSecretData,
 	}
 
-	_, err = v.vaultAPI.Logical().Write(secretName, data)
+	_, err = client.Logical().Write(secretName, data)
 	if err != nil {
 		return errors.Wrapf(err, "error writing secret %s to Hashicorp Vault %s", secretName, location)
 	}
@@ -64,10 +106,6 @@ func (v vaultSecretManager) SetSecret(location, secretName string, secretValue *
 }
 
 func getSecret(client *api.Client, location, secretName string) (*api.Secret, error) {
-	err := client.SetAddress(location)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error setting location of Hashicorp vault %s on client", location)
-	}
 	logical := client.Logical()
 	secret, err := logical.Read(secretName)
 	if err != nil {
@@ -88,10 +126,10 @@ func getSecretData(secret *api.Secret) (map[string]interface{}, error) {
 	return mapData, nil
 }
 
-func getSecretKeyString(secretData map[string]interface{}, secretKey string) (string, error) {
+func getSecretKeyString(location, secretName string, secretData map[string]interface{}, secretKey string) (string, error) {
 	value, ok := secretData[secretKey]
 	if !ok {
-		return "", fmt.Errorf("%s does not occur in secret data", secretKey)
+		return "", &secretstore.NotFoundError{Location: location, SecretName: secretName, SecretKey: secretKey}
 	}
 	stringValue, ok := value.(string)
 	if !ok {
@@ -0,0 +1,52 @@
+package vaultsecrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/pkg/errors"
+)
+
+// Watch polls the current_version of a KV version 2 secret from its metadata, so the secret name must be a KV v2
+// data path such as secret/data/my-secret
+func (v vaultSecretManager) Watch(location, secretName string) (secretstore.Watcher, error) {
+	metadataPath, err := kvMetadataPath(secretName)
+	if err != nil {
+		return nil, err
+	}
+	return secretstore.NewPollingWatcher(location, secretName, secretstore.DefaultWatchPollInterval, func() (string, error) {
+		return v.currentVersion(location, secretName, metadataPath)
+	}), nil
+}
+
+func (v vaultSecretManager) currentVersion(location, secretName, metadataPath string) (string, error) {
+	client, err := v.client(location)
+	if err != nil {
+		return "", err
+	}
+	metadata, err := getSecret(client, location, metadataPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading metadata for secret %s from Hashicorp Vault %s", secretName, location)
+	}
+	if metadata == nil {
+		return "", &secretstore.NotFoundError{Location: location, SecretName: secretName}
+	}
+	switch version := metadata.Data["current_version"].(type) {
+	case json.Number:
+		return version.String(), nil
+	case float64:
+		return fmt.Sprintf("%.0f", version), nil
+	}
+	return "", fmt.Errorf("current_version missing from metadata of secret %s in Hashicorp Vault %s", secretName, location)
+}
+
+// kvMetadataPath converts a KV v2 data path, <mount>/data/<path>, to its metadata path, <mount>/metadata/<path>
+func kvMetadataPath(secretName string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(secretName, "/"), "/data/", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("secret %s is not a Hashicorp Vault KV version 2 data path so cannot be watched", secretName)
+	}
+	return parts[0] + "/metadata/" + parts[1], nil
+}
@@ -0,0 +1,151 @@
+package secretstore
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultWatchPollInterval is how often secret managers without a native change notification mechanism check a
+// watched secret for a new version
+var DefaultWatchPollInterval = 30 * time.Second
+
+// EventType describes the kind of change to a watched secret
+type EventType string
+
+const (
+	EventTypeAdded    EventType = "Added"
+	EventTypeModified EventType = "Modified"
+	EventTypeDeleted  EventType = "Deleted"
+	// EventTypeError is sent when the change could not be determined, Err holds the cause
+	EventTypeError EventType = "Error"
+)
+
+// Event notifies a change to a watched secret. It carries the new version of the secret but never its value.
+type Event struct {
+	Type       EventType
+	Location   string
+	SecretName string
+	// Version identifies the version of the secret after the change in the backend's own format
+	Version string
+	Err     error
+}
+
+// Watcher delivers change events for a single secret until it is stopped
+type Watcher interface {
+	// ResultChan returns the channel events are delivered on. It is closed once the watcher stops.
+	ResultChan() <-chan Event
+	// Stop ends the watch and releases its resources
+	Stop()
+}
+
+// VersionFunc returns the current version of a secret, or an error satisfying IsNotFound if it does not exist
+type VersionFunc func() (string, error)
+
+// ContextVersionFunc is a VersionFunc whose requests are cancelled with the context
+type ContextVersionFunc func(ctx context.Context) (string, error)
+
+// NewPollingWatcher returns a Watcher which calls versionFunc every interval and sends an event whenever the version
+// changes. The version found when the watch starts is the baseline and is not reported.
+func NewPollingWatcher(location, secretName string, interval time.Duration, versionFunc VersionFunc) Watcher {
+	return NewPollingWatcherWithContext(location, secretName, interval, func(context.Context) (string, error) {
+		return versionFunc()
+	})
+}
+
+// NewPollingWatcherWithContext returns a polling Watcher like NewPollingWatcher, calling versionFunc with a context
+// that Stop cancels so a request in flight doesn't outlive the watcher
+func NewPollingWatcherWithContext(location, secretName string, interval time.Duration, versionFunc ContextVersionFunc) Watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &pollingWatcher{
+		location:    location,
+		secretName:  secretName,
+		interval:    interval,
+		versionFunc: versionFunc,
+		result:      make(chan Event),
+		cancel:      cancel,
+	}
+	go w.run(ctx)
+	return w
+}
+
+type pollingWatcher struct {
+	location    string
+	secretName  string
+	interval    time.Duration
+	versionFunc ContextVersionFunc
+	result      chan Event
+	cancel      context.CancelFunc
+}
+
+func (w *pollingWatcher) ResultChan() <-chan Event {
+	return w.result
+}
+
+func (w *pollingWatcher) Stop() {
+	w.cancel()
+}
+
+func (w *pollingWatcher) run(ctx context.Context) {
+	defer close(w.result)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	version, exists, err := w.poll(ctx)
+	baselineKnown := err == nil
+	if err != nil && !w.send(ctx, Event{Type: EventTypeError, Err: err}) {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		newVersion, newExists, err := w.poll(ctx)
+		var event Event
+		switch {
+		case err != nil:
+			event = Event{Type: EventTypeError, Err: err}
+		case !baselineKnown:
+			version, exists, baselineKnown = newVersion, newExists, true
+			continue
+		case newExists && !exists:
+			event = Event{Type: EventTypeAdded, Version: newVersion}
+		case !newExists && exists:
+			event = Event{Type: EventTypeDeleted, Version: version}
+		case newExists && newVersion != version:
+			event = Event{Type: EventTypeModified, Version: newVersion}
+		default:
+			continue
+		}
+		if err == nil {
+			version, exists = newVersion, newExists
+		}
+		if !w.send(ctx, event) {
+			return
+		}
+	}
+}
+
+func (w *pollingWatcher) poll(ctx context.Context) (string, bool, error) {
+	version, err := w.versionFunc(ctx)
+	if err != nil {
+		if IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return version, true, nil
+}
+
+func (w *pollingWatcher) send(ctx context.Context, event Event) bool {
+	event.Location = w.location
+	event.SecretName = w.secretName
+	select {
+	case <-ctx.Done():
+		return false
+	case w.result <- event:
+		return true
+	}
+}
@@ -0,0 +1,85 @@
+package secretstore_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollingWatcher(t *testing.T) {
+	var mu sync.Mutex
+	versions := []string{"1", "1", "2", "", "3"}
+	versionFunc := func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(versions) == 0 {
+			return "3", nil
+		}
+		v := versions[0]
+		versions = versions[1:]
+		if v == "" {
+			return "", &secretstore.NotFoundError{Location: "loc", SecretName: "name"}
+		}
+		return v, nil
+	}
+
+	w := secretstore.NewPollingWatcher("loc", "name", time.Millisecond, versionFunc)
+	defer w.Stop()
+
+	var events []secretstore.Event
+	for len(events) < 3 {
+		select {
+		case e := <-w.ResultChan():
+			events = append(events, e)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for events")
+		}
+	}
+	assert.Equal(t, []secretstore.Event{
+		{Type: secretstore.EventTypeModified, Location: "loc", SecretName: "name", Version: "2"},
+		{Type: secretstore.EventTypeDeleted, Location: "loc", SecretName: "name", Version: "2"},
+		{Type: secretstore.EventTypeAdded, Location: "loc", SecretName: "name", Version: "3"},
+	}, events)
+
+	w.Stop()
+	for range w.ResultChan() {
+	}
+}
+
+func TestPollingWatcherReportsErrors(t *testing.T) {
+	w := secretstore.NewPollingWatcher("loc", "name", time.Millisecond, func() (string, error) {
+		return "", fmt.Errorf("access denied")
+	})
+	defer w.Stop()
+
+	e := <-w.ResultChan()
+	assert.Equal(t, secretstore.EventTypeError, e.Type)
+	assert.EqualError(t, e.Err, "access denied")
+}
+
+func TestPollingWatcherStopCancelsVersionFunc(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	w := secretstore.NewPollingWatcherWithContext("loc", "name", time.Hour, func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return "", ctx.Err()
+	})
+
+	<-started
+	w.Stop()
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the request in flight was not cancelled by Stop")
+	}
+	for range w.ResultChan() {
+	}
+}
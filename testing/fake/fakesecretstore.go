@@ -1,8 +1,6 @@
 package fake
 
 import (
-	"fmt"
-
 	"github.com/jenkins-x-plugins/secretfacade/pkg/secretstore"
 )
 
@@ -30,9 +28,11 @@ func (f SecretStore) GetSecret(location, secretName, secretKey string) (string,
 			return v, nil
 		}
 	}
-	return "", fmt.Errorf("unable to find key %s in secret %s", secretKey, secretName)
+	return "", &secretstore.NotFoundError{Location: location, SecretName: secretName, SecretKey: secretKey}
 }
 
+// SetSecret stores the secret value. Property values are merged into those of an existing secret unless Overwrite is
+// set, as the secret managers of most secret stores do.
 func (f SecretStore) SetSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
 	var secrets map[string]secretType
 	var ok bool
@@ -41,10 +41,39 @@ func (f SecretStore) SetSecret(location, secretName string, secretValue *secrets
 		f.secretStores[location] = secrets
 	}
 
+	values := *secretValue
+	if existing, ok := secrets[secretName]; ok && !secretValue.Overwrite && secretValue.PropertyValues != nil {
+		values.PropertyValues = map[string]string{}
+		for k, v := range existing.values.PropertyValues {
+			values.PropertyValues[k] = v
+		}
+		for k, v := range secretValue.PropertyValues {
+			values.PropertyValues[k] = v
+		}
+	}
 	secrets[secretName] = secretType{
 		secretName: secretName,
-		values:     *secretValue,
+		values:     values,
 	}
 
 	return nil
 }
+
+// CreateSecret stores the secret value, returning a secretstore.ConflictError if the secret already exists
+func (f SecretStore) CreateSecret(location, secretName string, secretValue *secretstore.SecretValue) error {
+	if _, ok := f.secretStores[location][secretName]; ok {
+		return &secretstore.ConflictError{Location: location, SecretName: secretName}
+	}
+	return f.SetSecret(location, secretName, secretValue)
+}
+
+func (f SecretStore) GetSecretMetadata(location, secretName string) (*secretstore.SecretMetadata, error) {
+	secret, ok := f.secretStores[location][secretName]
+	if !ok {
+		return nil, &secretstore.NotFoundError{Location: location, SecretName: secretName}
+	}
+	return &secretstore.SecretMetadata{
+		Labels:      secret.values.Labels,
+		Annotations: secret.values.Annotations,
+	}, nil
+}
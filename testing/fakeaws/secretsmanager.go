@@ -0,0 +1,554 @@
+package fakeaws
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Staging labels of secret versions
+const (
+	stageCurrent  = "AWSCURRENT"
+	stagePrevious = "AWSPREVIOUS"
+)
+
+// SecretsManagerServer is an in-memory implementation of the AWS Secrets Manager JSON API for tests. Each region has
+// its own secrets; replicas are recorded in the replication status of their primary secret only.
+type SecretsManagerServer struct {
+	*server
+
+	lock    sync.Mutex
+	secrets map[string]map[string]*smSecret
+}
+
+type smSecret struct {
+	arn         string
+	name        string
+	description string
+	kmsKeyID    string
+	tags        []Tag
+	versions    map[string]*smVersion
+	created     time.Time
+	lastChanged time.Time
+	replicas    []ReplicationStatus
+}
+
+type smVersion struct {
+	id           string
+	secretString *string
+	secretBinary []byte
+	stages       []string
+	created      time.Time
+}
+
+// Tag is a Secrets Manager or Systems Manager resource tag
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// ReplicationStatus is the state of the replica of a secret
+type ReplicationStatus struct {
+	Region        string
+	KMSKeyID      string `json:"KmsKeyId,omitempty"`
+	Status        string
+	StatusMessage string `json:",omitempty"`
+}
+
+// NewSecretsManagerServer starts a fake Secrets Manager. Use its URL as the endpoint of the client.
+func NewSecretsManagerServer() *SecretsManagerServer {
+	s := &SecretsManagerServer{secrets: map[string]map[string]*smSecret{}}
+	s.server = newServer(map[string]operation{
+		"CreateSecret":                 s.createSecret,
+		"DeleteSecret":                 s.deleteSecret,
+		"DescribeSecret":               s.describeSecret,
+		"GetSecretValue":               s.getSecretValue,
+		"BatchGetSecretValue":          s.batchGetSecretValue,
+		"PutSecretValue":               s.putSecretValue,
+		"UpdateSecret":                 s.updateSecret,
+		"UpdateSecretVersionStage":     s.updateSecretVersionStage,
+		"TagResource":                  s.tagResource,
+		"UntagResource":                s.untagResource,
+		"ReplicateSecretToRegions":     s.replicateSecretToRegions,
+		"RemoveRegionsFromReplication": s.removeRegionsFromReplication,
+	})
+	return s
+}
+
+type secretValueResponse struct {
+	ARN           string
+	Name          string
+	VersionID     string  `json:"VersionId"`
+	SecretString  *string `json:",omitempty"`
+	SecretBinary  []byte  `json:",omitempty"`
+	VersionStages []string
+	CreatedDate   float64
+}
+
+func (s *SecretsManagerServer) createSecret(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Name               string
+		Description        string
+		KMSKeyID           string
+		SecretString       *string
+		SecretBinary       []byte
+		Tags               []Tag
+		ClientRequestToken string
+		AddReplicaRegions  []struct {
+			Region   string
+			KMSKeyID string
+		}
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secrets := s.regionSecrets(region)
+	if _, ok := secrets[req.Name]; ok {
+		return nil, newError("ResourceExistsException", "The operation failed because the secret %s already exists.", req.Name)
+	}
+	now := time.Now()
+	secret := &smSecret{
+		arn:         fmt.Sprintf("arn:aws:secretsmanager:%s:%s:secret:%s-%s", region, AccountID, req.Name, uuid.New().String()[:6]),
+		name:        req.Name,
+		description: req.Description,
+		kmsKeyID:    req.KMSKeyID,
+		tags:        req.Tags,
+		versions:    map[string]*smVersion{},
+		created:     now,
+		lastChanged: now,
+	}
+	for _, r := range req.AddReplicaRegions {
+		secret.replicas = append(secret.replicas, ReplicationStatus{Region: r.Region, KMSKeyID: r.KMSKeyID, Status: "InSync"})
+	}
+	secrets[req.Name] = secret
+
+	resp := map[string]interface{}{"ARN": secret.arn, "Name": secret.name, "ReplicationStatus": secret.replicas}
+	if req.SecretString != nil || req.SecretBinary != nil {
+		v := secret.addVersion(req.ClientRequestToken, req.SecretString, req.SecretBinary, []string{stageCurrent})
+		resp["VersionId"] = v.id
+	}
+	return resp, nil
+}
+
+func (s *SecretsManagerServer) deleteSecret(region string, body []byte) (interface{}, error) {
+	var req struct{ SecretID string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.regionSecrets(region), secret.name)
+	return map[string]interface{}{"ARN": secret.arn, "Name": secret.name, "DeletionDate": timestamp(time.Now())}, nil
+}
+
+func (s *SecretsManagerServer) describeSecret(region string, body []byte) (interface{}, error) {
+	var req struct{ SecretID string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	stages := map[string][]string{}
+	for id, v := range secret.versions {
+		stages[id] = v.stages
+	}
+	resp := map[string]interface{}{
+		"ARN":                secret.arn,
+		"Name":               secret.name,
+		"Tags":               secret.tags,
+		"VersionIdsToStages": stages,
+		"CreatedDate":        timestamp(secret.created),
+		"LastChangedDate":    timestamp(secret.lastChanged),
+		"PrimaryRegion":      region,
+		"ReplicationStatus":  secret.replicas,
+	}
+	if secret.description != "" {
+		resp["Description"] = secret.description
+	}
+	if secret.kmsKeyID != "" {
+		resp["KmsKeyId"] = secret.kmsKeyID
+	}
+	return resp, nil
+}
+
+func (s *SecretsManagerServer) getSecretValue(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID     string
+		VersionID    string
+		VersionStage string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	v, err := secret.getVersion(req.VersionID, req.VersionStage)
+	if err != nil {
+		return nil, err
+	}
+	return secret.valueResponse(v), nil
+}
+
+func (s *SecretsManagerServer) batchGetSecretValue(region string, body []byte) (interface{}, error) {
+	var req struct{ SecretIDList []string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	values := []secretValueResponse{}
+	errs := []map[string]string{}
+	for _, id := range req.SecretIDList {
+		secret, err := s.getSecret(region, id)
+		var v *smVersion
+		if err == nil {
+			v, err = secret.getVersion("", "")
+		}
+		if err != nil {
+			apiErr := err.(*apiError)
+			errs = append(errs, map[string]string{"SecretId": id, "ErrorCode": apiErr.code, "Message": apiErr.message})
+			continue
+		}
+		values = append(values, secret.valueResponse(v))
+	}
+	return map[string]interface{}{"SecretValues": values, "Errors": errs}, nil
+}
+
+func (s *SecretsManagerServer) putSecretValue(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID           string
+		SecretString       *string
+		SecretBinary       []byte
+		VersionStages      []string
+		ClientRequestToken string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	if req.SecretString == nil && req.SecretBinary == nil {
+		return nil, newError("InvalidRequestException", "You must provide either SecretString or SecretBinary.")
+	}
+	stages := req.VersionStages
+	if len(stages) == 0 {
+		stages = []string{stageCurrent}
+	}
+	v := secret.addVersion(req.ClientRequestToken, req.SecretString, req.SecretBinary, stages)
+	return map[string]interface{}{"ARN": secret.arn, "Name": secret.name, "VersionId": v.id, "VersionStages": v.stages}, nil
+}
+
+func (s *SecretsManagerServer) updateSecret(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID           string
+		Description        *string
+		KMSKeyID           *string
+		SecretString       *string
+		SecretBinary       []byte
+		ClientRequestToken string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Description != nil {
+		secret.description = *req.Description
+	}
+	if req.KMSKeyID != nil {
+		secret.kmsKeyID = *req.KMSKeyID
+	}
+	secret.lastChanged = time.Now()
+	resp := map[string]interface{}{"ARN": secret.arn, "Name": secret.name}
+	if req.SecretString != nil || req.SecretBinary != nil {
+		v := secret.addVersion(req.ClientRequestToken, req.SecretString, req.SecretBinary, []string{stageCurrent})
+		resp["VersionId"] = v.id
+	}
+	return resp, nil
+}
+
+func (s *SecretsManagerServer) updateSecretVersionStage(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID            string
+		VersionStage        string
+		MoveToVersionID     string
+		RemoveFromVersionID string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	var holder *smVersion
+	for _, v := range secret.versions {
+		if hasStage(v, req.VersionStage) {
+			holder = v
+		}
+	}
+	if holder != nil && holder.id != req.RemoveFromVersionID && req.MoveToVersionID != "" && holder.id != req.MoveToVersionID {
+		return nil, newError("InvalidParameterException", "The staging label %s is currently attached to version %s, so you must explicitly reference that version in RemoveFromVersionId.", req.VersionStage, holder.id)
+	}
+	if req.RemoveFromVersionID != "" {
+		v, ok := secret.versions[req.RemoveFromVersionID]
+		if !ok || !hasStage(v, req.VersionStage) {
+			return nil, newError("InvalidParameterException", "The staging label %s is not attached to version %s.", req.VersionStage, req.RemoveFromVersionID)
+		}
+	}
+	if req.MoveToVersionID != "" {
+		if _, ok := secret.versions[req.MoveToVersionID]; !ok {
+			return nil, newError("ResourceNotFoundException", "Secrets Manager can't find the specified secret version.")
+		}
+		secret.moveStage(req.VersionStage, req.MoveToVersionID)
+	} else if req.RemoveFromVersionID != "" {
+		removeStage(secret.versions[req.RemoveFromVersionID], req.VersionStage)
+	}
+	secret.lastChanged = time.Now()
+	return map[string]interface{}{"ARN": secret.arn, "Name": secret.name}, nil
+}
+
+func (s *SecretsManagerServer) tagResource(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID string
+		Tags     []Tag
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range req.Tags {
+		secret.tags = setTag(secret.tags, t)
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (s *SecretsManagerServer) untagResource(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID string
+		TagKeys  []string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	secret.tags = removeTags(secret.tags, req.TagKeys)
+	return map[string]interface{}{}, nil
+}
+
+func (s *SecretsManagerServer) replicateSecretToRegions(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID          string
+		AddReplicaRegions []struct {
+			Region   string
+			KMSKeyID string
+		}
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range req.AddReplicaRegions {
+		for _, existing := range secret.replicas {
+			if existing.Region == r.Region {
+				return nil, newError("InvalidParameterException", "The secret is already replicated to %s.", r.Region)
+			}
+		}
+		secret.replicas = append(secret.replicas, ReplicationStatus{Region: r.Region, KMSKeyID: r.KMSKeyID, Status: "InSync"})
+	}
+	return map[string]interface{}{"ARN": secret.arn, "ReplicationStatus": secret.replicas}, nil
+}
+
+func (s *SecretsManagerServer) removeRegionsFromReplication(region string, body []byte) (interface{}, error) {
+	var req struct {
+		SecretID             string
+		RemoveReplicaRegions []string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, err := s.getSecret(region, req.SecretID)
+	if err != nil {
+		return nil, err
+	}
+	removed := map[string]bool{}
+	for _, r := range req.RemoveReplicaRegions {
+		removed[r] = true
+	}
+	var replicas []ReplicationStatus
+	for _, r := range secret.replicas {
+		if !removed[r.Region] {
+			replicas = append(replicas, r)
+		}
+	}
+	secret.replicas = replicas
+	return map[string]interface{}{"ARN": secret.arn, "ReplicationStatus": secret.replicas}, nil
+}
+
+func (s *SecretsManagerServer) regionSecrets(region string) map[string]*smSecret {
+	secrets, ok := s.secrets[region]
+	if !ok {
+		secrets = map[string]*smSecret{}
+		s.secrets[region] = secrets
+	}
+	return secrets
+}
+
+// getSecret finds a secret by name or ARN
+func (s *SecretsManagerServer) getSecret(region, id string) (*smSecret, error) {
+	for _, secret := range s.regionSecrets(region) {
+		if secret.name == id || secret.arn == id {
+			return secret, nil
+		}
+	}
+	return nil, newError("ResourceNotFoundException", "Secrets Manager can't find the specified secret.")
+}
+
+func (secret *smSecret) addVersion(id string, secretString *string, secretBinary []byte, stages []string) *smVersion {
+	if id == "" {
+		id = uuid.New().String()
+	}
+	v := &smVersion{id: id, secretString: secretString, secretBinary: secretBinary, created: time.Now()}
+	secret.versions[id] = v
+	for _, stage := range stages {
+		secret.moveStage(stage, id)
+	}
+	secret.lastChanged = v.created
+	return v
+}
+
+// moveStage attaches a staging label to a version, removing it from any other. The version losing AWSCURRENT becomes
+// AWSPREVIOUS.
+func (secret *smSecret) moveStage(stage, id string) {
+	for _, v := range secret.versions {
+		if v.id == id || !hasStage(v, stage) {
+			continue
+		}
+		removeStage(v, stage)
+		if stage == stageCurrent {
+			secret.moveStage(stagePrevious, v.id)
+		}
+	}
+	if v := secret.versions[id]; !hasStage(v, stage) {
+		v.stages = append(v.stages, stage)
+	}
+}
+
+func (secret *smSecret) getVersion(id, stage string) (*smVersion, error) {
+	if id != "" {
+		if v, ok := secret.versions[id]; ok && (stage == "" || hasStage(v, stage)) {
+			return v, nil
+		}
+		return nil, newError("ResourceNotFoundException", "Secrets Manager can't find the specified secret value for VersionId: %s", id)
+	}
+	if stage == "" {
+		stage = stageCurrent
+	}
+	for _, v := range secret.versions {
+		if hasStage(v, stage) {
+			return v, nil
+		}
+	}
+	return nil, newError("ResourceNotFoundException", "Secrets Manager can't find the specified secret value for staging label: %s", stage)
+}
+
+func (secret *smSecret) valueResponse(v *smVersion) secretValueResponse {
+	stages := append([]string(nil), v.stages...)
+	sort.Strings(stages)
+	return secretValueResponse{
+		ARN:           secret.arn,
+		Name:          secret.name,
+		VersionID:     v.id,
+		SecretString:  v.secretString,
+		SecretBinary:  v.secretBinary,
+		VersionStages: stages,
+		CreatedDate:   timestamp(v.created),
+	}
+}
+
+func hasStage(v *smVersion, stage string) bool {
+	for _, s := range v.stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func removeStage(v *smVersion, stage string) {
+	var stages []string
+	for _, s := range v.stages {
+		if s != stage {
+			stages = append(stages, s)
+		}
+	}
+	v.stages = stages
+}
+
+func setTag(tags []Tag, tag Tag) []Tag {
+	for i := range tags {
+		if tags[i].Key == tag.Key {
+			tags[i].Value = tag.Value
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+func removeTags(tags []Tag, keys []string) []Tag {
+	removed := map[string]bool{}
+	for _, k := range keys {
+		removed[k] = true
+	}
+	var kept []Tag
+	for _, t := range tags {
+		if !removed[t.Key] {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,126 @@
+package fakeaws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	awsv2 "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// DefaultRegion is the region of sessions returned by NewSession
+const DefaultRegion = "us-east-1"
+
+// AccountID is the account of the ARNs of fake resources
+const AccountID = "123456789012"
+
+// apiError is an error returned in the format of the AWS JSON protocol
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newError(code, format string, args ...interface{}) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// operation handles the JSON body of a request for a region and returns the response to encode as JSON
+type operation func(region string, body []byte) (interface{}, error)
+
+// server serves an AWS JSON 1.1 protocol API, dispatching on the X-Amz-Target header
+type server struct {
+	*httptest.Server
+	operations map[string]operation
+}
+
+func newServer(operations map[string]operation) *server {
+	s := &server{operations: operations}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// NewSession returns a session with static credentials for use with the fakes
+func NewSession() (*session.Session, error) {
+	return session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("AKIAFAKE", "fake-secret", ""),
+		Region:      aws.String(DefaultRegion),
+	})
+}
+
+// NewConfig returns an aws-sdk-go-v2 config with static credentials for use with the fakes
+func NewConfig() awsv2.Config {
+	return awsv2.Config{
+		Region: DefaultRegion,
+		Credentials: awsv2.CredentialsProviderFunc(func(context.Context) (awsv2.Credentials, error) {
+			return awsv2.Credentials{AccessKeyID: "AKIAFAKE", SecretAccessKey: "fake-secret"}, nil
+		}),
+	}
+}
+
+func (s *server) handle(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	name := target[strings.LastIndex(target, ".")+1:]
+	op, ok := s.operations[name]
+	if !ok {
+		writeError(w, &apiError{status: http.StatusBadRequest, code: "UnknownOperationException", message: "unsupported operation " + target})
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, newError("SerializationException", "%s", err))
+		return
+	}
+	resp, err := op(requestRegion(r), body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*apiError)
+	if !ok {
+		apiErr = &apiError{status: http.StatusInternalServerError, code: "InternalFailure", message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(apiErr.status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": apiErr.code, "message": apiErr.message})
+}
+
+var credentialScope = regexp.MustCompile(`Credential=[^/]+/\d+/([^/]+)/`)
+
+// requestRegion returns the region a request was signed for
+func requestRegion(r *http.Request) string {
+	if m := credentialScope.FindStringSubmatch(r.Header.Get("Authorization")); m != nil {
+		return m[1]
+	}
+	return DefaultRegion
+}
+
+func decode(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return newError("SerializationException", "%s", err)
+	}
+	return nil
+}
+
+// timestamp converts a time to the epoch seconds used by the JSON protocol
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
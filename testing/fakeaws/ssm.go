@@ -0,0 +1,618 @@
+package fakeaws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Parameter tiers
+const (
+	tierStandard           = "Standard"
+	tierAdvanced           = "Advanced"
+	tierIntelligentTiering = "Intelligent-Tiering"
+)
+
+// maxStandardValueSize is the largest value a standard tier parameter can hold
+const maxStandardValueSize = 4096
+
+// SSMServer is an in-memory implementation of the AWS Systems Manager Parameter Store JSON API for tests. Each region
+// has its own parameters. SecureString values are returned base64 encoded unless decryption is requested.
+type SSMServer struct {
+	*server
+
+	lock       sync.Mutex
+	parameters map[string]map[string]*ssmParameter
+}
+
+type ssmParameter struct {
+	name     string
+	tags     []Tag
+	versions []*ssmVersion
+}
+
+type ssmVersion struct {
+	version     int64
+	typ         string
+	value       string
+	keyID       string
+	description string
+	tier        string
+	policies    string
+	dataType    string
+	labels      []string
+	modified    time.Time
+}
+
+type parameterResponse struct {
+	Name             string
+	Type             string
+	Value            string
+	Version          int64
+	Selector         string `json:",omitempty"`
+	LastModifiedDate float64
+	ARN              string
+	DataType         string
+}
+
+type policyResponse struct {
+	PolicyText   string
+	PolicyType   string
+	PolicyStatus string
+}
+
+type parameterHistoryResponse struct {
+	Name             string
+	Type             string
+	KeyID            string `json:"KeyId,omitempty"`
+	Value            string
+	Version          int64
+	Labels           []string
+	Tier             string
+	Description      string `json:",omitempty"`
+	Policies         []policyResponse
+	DataType         string
+	LastModifiedDate float64
+}
+
+// NewSSMServer starts a fake Parameter Store. Use its URL as the endpoint of the client.
+func NewSSMServer() *SSMServer {
+	s := &SSMServer{parameters: map[string]map[string]*ssmParameter{}}
+	s.server = newServer(map[string]operation{
+		"PutParameter":           s.putParameter,
+		"GetParameter":           s.getParameter,
+		"GetParameters":          s.getParameters,
+		"GetParametersByPath":    s.getParametersByPath,
+		"GetParameterHistory":    s.getParameterHistory,
+		"DeleteParameter":        s.deleteParameter,
+		"DeleteParameters":       s.deleteParameters,
+		"LabelParameterVersion":  s.labelParameterVersion,
+		"AddTagsToResource":      s.addTagsToResource,
+		"RemoveTagsFromResource": s.removeTagsFromResource,
+		"ListTagsForResource":    s.listTagsForResource,
+	})
+	return s
+}
+
+func (s *SSMServer) putParameter(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Name        string
+		Value       string
+		Type        string
+		Overwrite   bool
+		KeyID       string
+		Description string
+		Tier        string
+		Tags        []Tag
+		Policies    string
+		DataType    string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	parameters := s.regionParameters(region)
+	p, exists := parameters[req.Name]
+	if exists && !req.Overwrite {
+		return nil, newError("ParameterAlreadyExists", "The parameter already exists. To overwrite this value, set the overwrite option in the request to true.")
+	}
+	if req.Overwrite && len(req.Tags) > 0 {
+		return nil, newError("ValidationException", "Invalid request: tags and overwrite can't be used together. To create a parameter with tags, please remove overwrite flag. To update tags for an existing parameter, please use AddTagsToResource or RemoveTagsFromResource.")
+	}
+
+	v := &ssmVersion{
+		typ:         req.Type,
+		value:       req.Value,
+		keyID:       req.KeyID,
+		description: req.Description,
+		tier:        req.Tier,
+		policies:    req.Policies,
+		dataType:    req.DataType,
+		modified:    time.Now(),
+	}
+	if exists {
+		latest := p.latest()
+		if v.typ == "" {
+			v.typ = latest.typ
+		}
+		if v.keyID == "" && v.typ == latest.typ {
+			v.keyID = latest.keyID
+		}
+		if v.description == "" {
+			v.description = latest.description
+		}
+		if v.tier == "" && latest.tier == tierAdvanced {
+			// an advanced parameter can't be downgraded
+			v.tier = tierAdvanced
+		}
+	}
+	if v.typ == "" {
+		return nil, newError("ValidationException", "A parameter type is required when you create a parameter.")
+	}
+	if v.typ == "SecureString" && v.keyID == "" {
+		v.keyID = "alias/aws/ssm"
+	}
+	if v.dataType == "" {
+		v.dataType = "text"
+	}
+	switch v.tier {
+	case "", tierStandard:
+		v.tier = tierStandard
+	case tierAdvanced:
+	case tierIntelligentTiering:
+		v.tier = tierStandard
+		if req.Policies != "" || len(req.Value) > maxStandardValueSize {
+			v.tier = tierAdvanced
+		}
+	default:
+		return nil, newError("ValidationException", "1 validation error detected: Value '%s' at 'tier' failed to satisfy constraint", v.tier)
+	}
+	if v.tier == tierStandard && req.Policies != "" {
+		return nil, newError("InvalidPolicyTypeException", "Parameter policies are only supported for the advanced tier.")
+	}
+	if v.tier == tierStandard && len(req.Value) > maxStandardValueSize {
+		return nil, newError("ValidationException", "Standard tier parameters support a maximum parameter value of 4096 characters.")
+	}
+	if req.Policies != "" {
+		if _, err := parsePolicies(req.Policies); err != nil {
+			return nil, newError("InvalidPolicyAttributeException", "%s", err)
+		}
+	}
+
+	if !exists {
+		p = &ssmParameter{name: req.Name, tags: req.Tags}
+		parameters[req.Name] = p
+	}
+	v.version = int64(len(p.versions)) + 1
+	p.versions = append(p.versions, v)
+	return map[string]interface{}{"Version": v.version, "Tier": v.tier}, nil
+}
+
+func (s *SSMServer) getParameter(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Name           string
+		WithDecryption bool
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, v, selector, err := s.selectParameter(region, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	resp := p.response(region, v, req.WithDecryption)
+	resp.Selector = selector
+	return map[string]interface{}{"Parameter": resp}, nil
+}
+
+func (s *SSMServer) getParameters(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Names          []string
+		WithDecryption bool
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	parameters := []parameterResponse{}
+	invalid := []string{}
+	for _, name := range req.Names {
+		p, v, selector, err := s.selectParameter(region, name)
+		if err != nil {
+			invalid = append(invalid, name)
+			continue
+		}
+		resp := p.response(region, v, req.WithDecryption)
+		resp.Selector = selector
+		parameters = append(parameters, resp)
+	}
+	return map[string]interface{}{"Parameters": parameters, "InvalidParameters": invalid}, nil
+}
+
+func (s *SSMServer) getParametersByPath(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Path           string
+		Recursive      bool
+		WithDecryption bool
+		MaxResults     int
+		NextToken      string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(req.Path, "/") {
+		return nil, newError("ValidationException", "The parameter path must begin with a forward slash (/).")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prefix := strings.TrimSuffix(req.Path, "/") + "/"
+	var names []string
+	for name := range s.regionParameters(region) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !req.Recursive && strings.Contains(name[len(prefix):], "/") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	page, next, err := paginate(len(names), req.MaxResults, req.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	parameters := []parameterResponse{}
+	for _, name := range names[page[0]:page[1]] {
+		p := s.regionParameters(region)[name]
+		parameters = append(parameters, p.response(region, p.latest(), req.WithDecryption))
+	}
+	resp := map[string]interface{}{"Parameters": parameters}
+	if next != "" {
+		resp["NextToken"] = next
+	}
+	return resp, nil
+}
+
+func (s *SSMServer) getParameterHistory(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Name           string
+		WithDecryption bool
+		MaxResults     int
+		NextToken      string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, err := s.getParameterByName(region, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	page, next, err := paginate(len(p.versions), req.MaxResults, req.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	history := []parameterHistoryResponse{}
+	for _, v := range p.versions[page[0]:page[1]] {
+		policies, _ := parsePolicies(v.policies)
+		history = append(history, parameterHistoryResponse{
+			Name:             p.name,
+			Type:             v.typ,
+			KeyID:            v.keyID,
+			Value:            v.displayValue(req.WithDecryption),
+			Version:          v.version,
+			Labels:           v.labels,
+			Tier:             v.tier,
+			Description:      v.description,
+			Policies:         policies,
+			DataType:         v.dataType,
+			LastModifiedDate: timestamp(v.modified),
+		})
+	}
+	resp := map[string]interface{}{"Parameters": history}
+	if next != "" {
+		resp["NextToken"] = next
+	}
+	return resp, nil
+}
+
+func (s *SSMServer) deleteParameter(region string, body []byte) (interface{}, error) {
+	var req struct{ Name string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.getParameterByName(region, req.Name); err != nil {
+		return nil, err
+	}
+	delete(s.regionParameters(region), req.Name)
+	return map[string]interface{}{}, nil
+}
+
+func (s *SSMServer) deleteParameters(region string, body []byte) (interface{}, error) {
+	var req struct{ Names []string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Names) == 0 || len(req.Names) > 10 {
+		return nil, newError("ValidationException", "1 validation error detected: Value at 'names' failed to satisfy constraint: Member must have length between 1 and 10")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	parameters := s.regionParameters(region)
+	deleted, invalid := []string{}, []string{}
+	for _, name := range req.Names {
+		if _, ok := parameters[name]; !ok {
+			invalid = append(invalid, name)
+			continue
+		}
+		delete(parameters, name)
+		deleted = append(deleted, name)
+	}
+	return map[string]interface{}{"DeletedParameters": deleted, "InvalidParameters": invalid}, nil
+}
+
+func (s *SSMServer) labelParameterVersion(region string, body []byte) (interface{}, error) {
+	var req struct {
+		Name             string
+		ParameterVersion *int64
+		Labels           []string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, err := s.getParameterByName(region, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	v := p.latest()
+	if req.ParameterVersion != nil {
+		if *req.ParameterVersion < 1 || *req.ParameterVersion > int64(len(p.versions)) {
+			return nil, newError("ParameterVersionNotFound", "Systems Manager could not find version %d of %s.", *req.ParameterVersion, req.Name)
+		}
+		v = p.versions[*req.ParameterVersion-1]
+	}
+	invalid := []string{}
+	for _, label := range req.Labels {
+		if !validLabel(label) {
+			invalid = append(invalid, label)
+			continue
+		}
+		for _, other := range p.versions {
+			other.labels = removeString(other.labels, label)
+		}
+		v.labels = append(v.labels, label)
+	}
+	return map[string]interface{}{"InvalidLabels": invalid, "ParameterVersion": v.version}, nil
+}
+
+func (s *SSMServer) addTagsToResource(region string, body []byte) (interface{}, error) {
+	var req struct {
+		ResourceID string
+		Tags       []Tag
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, err := s.getTaggedParameter(region, req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range req.Tags {
+		p.tags = setTag(p.tags, t)
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (s *SSMServer) removeTagsFromResource(region string, body []byte) (interface{}, error) {
+	var req struct {
+		ResourceID string
+		TagKeys    []string
+	}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, err := s.getTaggedParameter(region, req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	p.tags = removeTags(p.tags, req.TagKeys)
+	return map[string]interface{}{}, nil
+}
+
+func (s *SSMServer) listTagsForResource(region string, body []byte) (interface{}, error) {
+	var req struct{ ResourceID string }
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, err := s.getTaggedParameter(region, req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	tags := append([]Tag{}, p.tags...)
+	return map[string]interface{}{"TagList": tags}, nil
+}
+
+func (s *SSMServer) regionParameters(region string) map[string]*ssmParameter {
+	parameters, ok := s.parameters[region]
+	if !ok {
+		parameters = map[string]*ssmParameter{}
+		s.parameters[region] = parameters
+	}
+	return parameters
+}
+
+func (s *SSMServer) getParameterByName(region, name string) (*ssmParameter, error) {
+	p, ok := s.regionParameters(region)[name]
+	if !ok {
+		return nil, newError("ParameterNotFound", "Parameter %s not found.", name)
+	}
+	return p, nil
+}
+
+func (s *SSMServer) getTaggedParameter(region, resourceID string) (*ssmParameter, error) {
+	p, ok := s.regionParameters(region)[resourceID]
+	if !ok {
+		return nil, newError("InvalidResourceId", "The resource ID %s is not valid.", resourceID)
+	}
+	return p, nil
+}
+
+// selectParameter finds a parameter by name, with an optional :version or :label selector
+func (s *SSMServer) selectParameter(region, name string) (*ssmParameter, *ssmVersion, string, error) {
+	selector := ""
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name, selector = name[:i], name[i:]
+	}
+	p, err := s.getParameterByName(region, name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if selector == "" {
+		return p, p.latest(), "", nil
+	}
+	if n, err := strconv.ParseInt(selector[1:], 10, 64); err == nil {
+		if n < 1 || n > int64(len(p.versions)) {
+			return nil, nil, "", newError("ParameterVersionNotFound", "Systems Manager could not find version %d of %s.", n, name)
+		}
+		return p, p.versions[n-1], selector, nil
+	}
+	for _, v := range p.versions {
+		for _, label := range v.labels {
+			if label == selector[1:] {
+				return p, v, selector, nil
+			}
+		}
+	}
+	return nil, nil, "", newError("ParameterVersionLabelNotFound", "Systems Manager could not find label %s of %s.", selector[1:], name)
+}
+
+func (p *ssmParameter) latest() *ssmVersion {
+	return p.versions[len(p.versions)-1]
+}
+
+func (p *ssmParameter) response(region string, v *ssmVersion, withDecryption bool) parameterResponse {
+	return parameterResponse{
+		Name:             p.name,
+		Type:             v.typ,
+		Value:            v.displayValue(withDecryption),
+		Version:          v.version,
+		LastModifiedDate: timestamp(v.modified),
+		ARN:              fmt.Sprintf("arn:aws:ssm:%s:%s:parameter/%s", region, AccountID, strings.TrimPrefix(p.name, "/")),
+		DataType:         v.dataType,
+	}
+}
+
+// displayValue returns the value of a version, standing in for the ciphertext of a SecureString unless decrypted
+func (v *ssmVersion) displayValue(withDecryption bool) string {
+	if v.typ == "SecureString" && !withDecryption {
+		return base64.StdEncoding.EncodeToString([]byte(v.keyID + ":" + v.value))
+	}
+	return v.value
+}
+
+// parsePolicies parses the JSON array of parameter policies
+func parsePolicies(policies string) ([]policyResponse, error) {
+	result := []policyResponse{}
+	if policies == "" {
+		return result, nil
+	}
+	var parsed []struct {
+		Type string
+	}
+	if err := json.Unmarshal([]byte(policies), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid parameter policies: %w", err)
+	}
+	var raw []json.RawMessage
+	_ = json.Unmarshal([]byte(policies), &raw)
+	for i, p := range parsed {
+		switch p.Type {
+		case "Expiration", "ExpirationNotification", "NoChangeNotification":
+		default:
+			return nil, fmt.Errorf("unknown parameter policy type %q", p.Type)
+		}
+		result = append(result, policyResponse{PolicyText: string(raw[i]), PolicyType: p.Type, PolicyStatus: "Pending"})
+	}
+	return result, nil
+}
+
+// paginate returns the [start, end) range of a page of n results and the token of the next page
+func paginate(n, maxResults int, token string) ([2]int, string, error) {
+	if maxResults <= 0 {
+		maxResults = 10
+	}
+	start := 0
+	if token != "" {
+		var err error
+		start, err = strconv.Atoi(token)
+		if err != nil || start < 0 || start > n {
+			return [2]int{}, "", newError("InvalidNextToken", "The specified token isn't valid.")
+		}
+	}
+	end := start + maxResults
+	if end >= n {
+		return [2]int{start, n}, "", nil
+	}
+	return [2]int{start, end}, strconv.Itoa(end), nil
+}
+
+// validLabel reports whether a label can be attached to a parameter version
+func validLabel(label string) bool {
+	if label == "" || len(label) > 100 {
+		return false
+	}
+	if _, err := strconv.Atoi(label); err == nil {
+		return false
+	}
+	lower := strings.ToLower(label)
+	return !strings.HasPrefix(lower, "aws") && !strings.HasPrefix(lower, "ssm") && !strings.ContainsAny(label, ":/ ")
+}
+
+func removeString(values []string, value string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// PutParameter stores a new version of a parameter directly, for seeding tests
+func (s *SSMServer) PutParameter(region, name, typ, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	parameters := s.regionParameters(region)
+	p, ok := parameters[name]
+	if !ok {
+		p = &ssmParameter{name: name}
+		parameters[name] = p
+	}
+	v := &ssmVersion{typ: typ, value: value, tier: tierStandard, dataType: "text", modified: time.Now()}
+	if typ == "SecureString" {
+		v.keyID = "alias/aws/ssm"
+	}
+	v.version = int64(len(p.versions)) + 1
+	p.versions = append(p.versions, v)
+}
@@ -0,0 +1,137 @@
+package fakeaws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// STSCall is a role assumed through the fake STS
+type STSCall struct {
+	Action           string
+	RoleARN          string
+	ExternalID       string
+	RoleSessionName  string
+	WebIdentityToken string
+	// AccessKeyID is the access key the call was signed with, empty for web identity calls which aren't signed
+	AccessKeyID string
+	// IssuedAccessKeyID is the access key of the credentials returned
+	IssuedAccessKeyID string
+}
+
+// STSServer is an in-memory implementation of the AssumeRole and AssumeRoleWithWebIdentity calls of the AWS STS query
+// API for tests. It issues new credentials for any role and records the calls made.
+type STSServer struct {
+	*httptest.Server
+
+	lock  sync.Mutex
+	calls []STSCall
+}
+
+type stsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+type stsResult struct {
+	XMLName         xml.Name
+	Credentials     stsCredentials
+	AssumedRoleUser struct {
+		Arn           string
+		AssumedRoleID string `xml:"AssumedRoleId"`
+	}
+}
+
+// NewSTSServer starts a fake STS. Use its URL as the STS endpoint.
+func NewSTSServer() *STSServer {
+	s := &STSServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Calls returns the calls made so far, oldest first
+func (s *STSServer) Calls() []STSCall {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]STSCall{}, s.calls...)
+}
+
+var accessKeyID = regexp.MustCompile(`Credential=([^/]+)/`)
+
+func (s *STSServer) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeSTSError(w, "InvalidParameterValue", err.Error())
+		return
+	}
+	action := r.PostForm.Get("Action")
+	if action != "AssumeRole" && action != "AssumeRoleWithWebIdentity" {
+		writeSTSError(w, "InvalidAction", "unsupported action "+action)
+		return
+	}
+	call := STSCall{
+		Action:           action,
+		RoleARN:          r.PostForm.Get("RoleArn"),
+		ExternalID:       r.PostForm.Get("ExternalId"),
+		RoleSessionName:  r.PostForm.Get("RoleSessionName"),
+		WebIdentityToken: r.PostForm.Get("WebIdentityToken"),
+	}
+	if m := accessKeyID.FindStringSubmatch(r.Header.Get("Authorization")); m != nil {
+		call.AccessKeyID = m[1]
+	}
+	if call.RoleARN == "" || call.RoleSessionName == "" {
+		writeSTSError(w, "ValidationError", "RoleArn and RoleSessionName are required")
+		return
+	}
+	if action == "AssumeRoleWithWebIdentity" && call.WebIdentityToken == "" {
+		writeSTSError(w, "ValidationError", "WebIdentityToken is required")
+		return
+	}
+
+	s.lock.Lock()
+	call.IssuedAccessKeyID = fmt.Sprintf("ASIAFAKE%04d", len(s.calls)+1)
+	s.calls = append(s.calls, call)
+	s.lock.Unlock()
+
+	result := stsResult{XMLName: xml.Name{Local: action + "Result"}}
+	result.Credentials = stsCredentials{
+		AccessKeyID:     call.IssuedAccessKeyID,
+		SecretAccessKey: "fake-secret-" + call.IssuedAccessKeyID,
+		SessionToken:    "fake-token-" + call.IssuedAccessKeyID,
+		Expiration:      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}
+	result.AssumedRoleUser.Arn = call.RoleARN + "/" + call.RoleSessionName
+	result.AssumedRoleUser.AssumedRoleID = "AROAFAKE:" + call.RoleSessionName
+
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName  xml.Name
+		Result   stsResult
+		Metadata struct {
+			RequestID string `xml:"RequestId"`
+		} `xml:"ResponseMetadata"`
+	}{
+		XMLName: xml.Name{Space: "https://sts.amazonaws.com/doc/2011-06-15/", Local: action + "Response"},
+		Result:  result,
+	})
+}
+
+type stsError struct {
+	Type    string
+	Code    string
+	Message string
+}
+
+func writeSTSError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"ErrorResponse"`
+		Error   stsError
+	}{Error: stsError{Type: "Sender", Code: code, Message: message}})
+}
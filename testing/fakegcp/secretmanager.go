@@ -0,0 +1,351 @@
+package fakegcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SecretManagerServer is an in-memory implementation of the GCP Secret Manager gRPC API for tests. It supports the
+// secret and secret version operations used by this module; IAM operations are unimplemented.
+type SecretManagerServer struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	// BeforeAddSecretVersion, when set, is called before each version is added, e.g. to simulate a concurrent write
+	BeforeAddSecretVersion func(req *secretmanagerpb.AddSecretVersionRequest)
+
+	lock    sync.Mutex
+	secrets map[string]*secret
+	etag    int
+
+	grpcServer *grpc.Server
+	listener   net.Listener
+}
+
+type secret struct {
+	secret   *secretmanagerpb.Secret
+	versions []*version
+}
+
+type version struct {
+	version *secretmanagerpb.SecretVersion
+	payload *secretmanagerpb.SecretPayload
+}
+
+// NewSecretManagerServer starts a fake Secret Manager listening on a random local port
+func NewSecretManagerServer() (*SecretManagerServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for fake secret manager: %w", err)
+	}
+	s := &SecretManagerServer{
+		secrets:    map[string]*secret{},
+		grpcServer: grpc.NewServer(),
+		listener:   listener,
+	}
+	secretmanagerpb.RegisterSecretManagerServiceServer(s.grpcServer, s)
+	go func() {
+		_ = s.grpcServer.Serve(listener)
+	}()
+	return s, nil
+}
+
+// Addr returns the host:port the server is listening on, for use as the client endpoint
+func (s *SecretManagerServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stop shuts the server down
+func (s *SecretManagerServer) Stop() {
+	s.grpcServer.Stop()
+}
+
+// Secret returns a copy of a stored secret by its resource name, or nil if it does not exist
+func (s *SecretManagerServer) Secret(name string) *secretmanagerpb.Secret {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if sec, ok := s.secrets[name]; ok {
+		return proto.Clone(sec.secret).(*secretmanagerpb.Secret)
+	}
+	return nil
+}
+
+// Versions returns copies of the versions of a secret by its resource name, oldest first
+func (s *SecretManagerServer) Versions(name string) []*secretmanagerpb.SecretVersion {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sec, ok := s.secrets[name]
+	if !ok {
+		return nil
+	}
+	versions := make([]*secretmanagerpb.SecretVersion, 0, len(sec.versions))
+	for _, v := range sec.versions {
+		versions = append(versions, proto.Clone(v.version).(*secretmanagerpb.SecretVersion))
+	}
+	return versions
+}
+
+func (s *SecretManagerServer) ListSecrets(_ context.Context, req *secretmanagerpb.ListSecretsRequest) (*secretmanagerpb.ListSecretsResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	resp := &secretmanagerpb.ListSecretsResponse{}
+	prefix := req.Parent + "/secrets/"
+	for name, sec := range s.secrets {
+		if strings.HasPrefix(name, prefix) {
+			resp.Secrets = append(resp.Secrets, proto.Clone(sec.secret).(*secretmanagerpb.Secret))
+		}
+	}
+	sort.Slice(resp.Secrets, func(i, j int) bool {
+		return resp.Secrets[i].Name < resp.Secrets[j].Name
+	})
+	resp.TotalSize = int32(len(resp.Secrets))
+	return resp, nil
+}
+
+func (s *SecretManagerServer) CreateSecret(_ context.Context, req *secretmanagerpb.CreateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if req.SecretId == "" {
+		return nil, status.Error(codes.InvalidArgument, "secret_id is required")
+	}
+	if req.Secret.GetReplication() == nil {
+		return nil, status.Error(codes.InvalidArgument, "replication is required")
+	}
+	name := req.Parent + "/secrets/" + req.SecretId
+	if _, ok := s.secrets[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "Secret [%s] already exists.", name)
+	}
+	created := proto.Clone(req.Secret).(*secretmanagerpb.Secret)
+	created.Name = name
+	created.CreateTime = timestamppb.Now()
+	if ttl := created.GetTtl(); ttl != nil {
+		created.Expiration = &secretmanagerpb.Secret_ExpireTime{
+			ExpireTime: timestamppb.New(created.CreateTime.AsTime().Add(ttl.AsDuration())),
+		}
+	}
+	if created.Rotation != nil && len(created.Topics) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a secret with rotation must have at least one topic")
+	}
+	created.Etag = s.nextEtag()
+	s.secrets[name] = &secret{secret: created}
+	return proto.Clone(created).(*secretmanagerpb.Secret), nil
+}
+
+func (s *SecretManagerServer) GetSecret(_ context.Context, req *secretmanagerpb.GetSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sec, err := s.getSecret(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(sec.secret).(*secretmanagerpb.Secret), nil
+}
+
+func (s *SecretManagerServer) UpdateSecret(_ context.Context, req *secretmanagerpb.UpdateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sec, err := s.getSecret(req.Secret.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if req.Secret.Etag != "" && req.Secret.Etag != sec.secret.Etag {
+		return nil, status.Errorf(codes.Aborted, "etag %s does not match the current etag of secret [%s]", req.Secret.Etag, sec.secret.Name)
+	}
+	if len(req.UpdateMask.GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+	updated := proto.Clone(sec.secret).(*secretmanagerpb.Secret)
+	for _, path := range req.UpdateMask.GetPaths() {
+		switch path {
+		case "labels":
+			updated.Labels = req.Secret.Labels
+		case "annotations":
+			updated.Annotations = req.Secret.Annotations
+		case "topics":
+			updated.Topics = req.Secret.Topics
+		case "rotation":
+			updated.Rotation = req.Secret.Rotation
+		case "expire_time":
+			updated.Expiration = nil
+			if req.Secret.GetExpireTime() != nil {
+				updated.Expiration = &secretmanagerpb.Secret_ExpireTime{ExpireTime: req.Secret.GetExpireTime()}
+			}
+		case "ttl":
+			updated.Expiration = nil
+			if ttl := req.Secret.GetTtl(); ttl != nil {
+				updated.Expiration = &secretmanagerpb.Secret_ExpireTime{
+					ExpireTime: timestamppb.New(timestamppb.Now().AsTime().Add(ttl.AsDuration())),
+				}
+			}
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "update_mask path %s is not supported", path)
+		}
+	}
+	if updated.Rotation != nil && len(updated.Topics) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a secret with rotation must have at least one topic")
+	}
+	updated.Etag = s.nextEtag()
+	sec.secret = updated
+	return proto.Clone(updated).(*secretmanagerpb.Secret), nil
+}
+
+func (s *SecretManagerServer) DeleteSecret(_ context.Context, req *secretmanagerpb.DeleteSecretRequest) (*emptypb.Empty, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sec, err := s.getSecret(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if req.Etag != "" && req.Etag != sec.secret.Etag {
+		return nil, status.Errorf(codes.Aborted, "etag %s does not match the current etag of secret [%s]", req.Etag, req.Name)
+	}
+	delete(s.secrets, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *SecretManagerServer) AddSecretVersion(_ context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	if s.BeforeAddSecretVersion != nil {
+		s.BeforeAddSecretVersion(req)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sec, err := s.getSecret(req.Parent)
+	if err != nil {
+		return nil, err
+	}
+	v := &version{
+		version: &secretmanagerpb.SecretVersion{
+			Name:       fmt.Sprintf("%s/versions/%d", req.Parent, len(sec.versions)+1),
+			CreateTime: timestamppb.Now(),
+			State:      secretmanagerpb.SecretVersion_ENABLED,
+			Etag:       s.nextEtag(),
+		},
+		payload: proto.Clone(req.Payload).(*secretmanagerpb.SecretPayload),
+	}
+	sec.versions = append(sec.versions, v)
+	return proto.Clone(v.version).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (s *SecretManagerServer) ListSecretVersions(_ context.Context, req *secretmanagerpb.ListSecretVersionsRequest) (*secretmanagerpb.ListSecretVersionsResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sec, err := s.getSecret(req.Parent)
+	if err != nil {
+		return nil, err
+	}
+	resp := &secretmanagerpb.ListSecretVersionsResponse{TotalSize: int32(len(sec.versions))}
+	// versions are listed newest first, as by Secret Manager
+	for i := len(sec.versions) - 1; i >= 0; i-- {
+		resp.Versions = append(resp.Versions, proto.Clone(sec.versions[i].version).(*secretmanagerpb.SecretVersion))
+	}
+	return resp, nil
+}
+
+func (s *SecretManagerServer) GetSecretVersion(_ context.Context, req *secretmanagerpb.GetSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, err := s.getVersion(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(v.version).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (s *SecretManagerServer) AccessSecretVersion(_ context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, err := s.getVersion(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if v.version.State != secretmanagerpb.SecretVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "Secret Version [%s] is in %s state.", v.version.Name, v.version.State)
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    v.version.Name,
+		Payload: proto.Clone(v.payload).(*secretmanagerpb.SecretPayload),
+	}, nil
+}
+
+func (s *SecretManagerServer) DisableSecretVersion(_ context.Context, req *secretmanagerpb.DisableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.Name, req.Etag, secretmanagerpb.SecretVersion_DISABLED)
+}
+
+func (s *SecretManagerServer) EnableSecretVersion(_ context.Context, req *secretmanagerpb.EnableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.Name, req.Etag, secretmanagerpb.SecretVersion_ENABLED)
+}
+
+func (s *SecretManagerServer) DestroySecretVersion(_ context.Context, req *secretmanagerpb.DestroySecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.Name, req.Etag, secretmanagerpb.SecretVersion_DESTROYED)
+}
+
+func (s *SecretManagerServer) setVersionState(name, etag string, state secretmanagerpb.SecretVersion_State) (*secretmanagerpb.SecretVersion, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, err := s.getVersion(name)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" && etag != v.version.Etag {
+		return nil, status.Errorf(codes.Aborted, "etag %s does not match the current etag of secret version [%s]", etag, name)
+	}
+	if v.version.State == secretmanagerpb.SecretVersion_DESTROYED {
+		return nil, status.Errorf(codes.FailedPrecondition, "Secret Version [%s] is in DESTROYED state.", v.version.Name)
+	}
+	v.version.State = state
+	v.version.Etag = s.nextEtag()
+	if state == secretmanagerpb.SecretVersion_DESTROYED {
+		v.version.DestroyTime = timestamppb.Now()
+		v.payload = nil
+	}
+	return proto.Clone(v.version).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (s *SecretManagerServer) getSecret(name string) (*secret, error) {
+	sec, ok := s.secrets[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Secret [%s] not found.", name)
+	}
+	return sec, nil
+}
+
+// getVersion resolves a version name, including the latest alias which refers to the most recently created version
+func (s *SecretManagerServer) getVersion(name string) (*version, error) {
+	i := strings.LastIndex(name, "/versions/")
+	if i < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid secret version name %s", name)
+	}
+	sec, err := s.getSecret(name[:i])
+	if err != nil {
+		return nil, err
+	}
+	id := name[i+len("/versions/"):]
+	if id == "latest" {
+		if len(sec.versions) == 0 {
+			return nil, status.Errorf(codes.NotFound, "Secret [%s] has no versions.", name[:i])
+		}
+		return sec.versions[len(sec.versions)-1], nil
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 || n > len(sec.versions) {
+		return nil, status.Errorf(codes.NotFound, "Secret Version [%s] not found.", name)
+	}
+	return sec.versions[n-1], nil
+}
+
+func (s *SecretManagerServer) nextEtag() string {
+	s.etag++
+	return fmt.Sprintf("\"%d\"", s.etag)
+}